@@ -1,22 +1,43 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/kovetskiy/aurora/pkg/proto"
 	"github.com/kovetskiy/aurora/pkg/rpc"
+	"github.com/kovetskiy/aurora/pkg/schedule"
+	"github.com/reconquest/karma-go"
 )
 
 func handleAdd(opts Options) error {
+	_, err := schedule.Parse(opts.Schedule)
+	if err != nil {
+		return karma.Format(err, "invalid schedule")
+	}
+
+	priority := 0
+	if opts.Priority != "" {
+		priority, err = strconv.Atoi(opts.Priority)
+		if err != nil {
+			return errors.New("priority should be an integer")
+		}
+	}
+
 	client := NewClient(opts.Address)
 	signer := NewSigner(opts.Key)
 
-	err := client.Call(
+	err = client.Call(
 		(*rpc.PackageService).AddPackage,
 		proto.RequestAddPackage{
 			Signature: signer.sign(),
 			Name:      opts.Package,
 			CloneURL:  opts.CloneURL,
+			Private:   opts.Private,
+			Priority:  priority,
+			Repos:     opts.Repo,
+			Schedule:  opts.Schedule,
 		},
 		&proto.ResponseAddPackage{},
 	)
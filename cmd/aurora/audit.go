@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/rpc"
+)
+
+func handleAudit(opts Options) error {
+	client := NewClient(opts.Address)
+	signer := NewSigner(opts.Key)
+
+	var response proto.ResponseListAuditRecords
+	err := client.Call(
+		(*rpc.AuditService).ListRecords,
+		proto.RequestListAuditRecords{
+			Signature: signer.sign(),
+			Signer:    opts.User,
+			Package:   opts.AuditPackage,
+		},
+		&response,
+	)
+	if err != nil {
+		return err
+	}
+
+	tab := tabwriter.NewWriter(os.Stdout, 1, 2, 3, ' ', 0)
+	fmt.Fprintf(tab, "TIME\tSIGNER\tMETHOD\tPARAMS\tSOURCE\tRESULT\n")
+
+	for _, record := range response.Records {
+		fmt.Fprintf(
+			tab,
+			"%s\t%s\t%s\t%s\t%s\t%s\n",
+			record.Time.Format(time.RFC3339),
+			record.Signer,
+			record.Method,
+			formatParams(record.Params),
+			record.Source,
+			record.Result,
+		)
+	}
+
+	return tab.Flush()
+}
+
+func formatParams(params map[string]interface{}) string {
+	keys := []string{}
+	for key := range params {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, params[key]))
+	}
+
+	return strings.Join(pairs, " ")
+}
@@ -4,11 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/kovetskiy/aurora/pkg/proto"
 	"github.com/kovetskiy/aurora/pkg/rpc"
+	"github.com/kovetskiy/aurora/pkg/schedule"
 	"github.com/kovetskiy/aurora/pkg/signature"
 )
 
@@ -57,23 +61,208 @@ func handleGetPackage(client *Client, name string, signature *signature.Signatur
 		return errors.New("package not found")
 	}
 
-	return printPackages(reply.Package)
+	err = printPackages(reply.Package)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+
+	err = printOptions(reply.Package)
+	if err != nil {
+		return err
+	}
+
+	printFailure(reply.Package.Failure)
+
+	if len(reply.Stages) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+
+	return printStages(reply.Stages)
+}
+
+func printOptions(pkg *proto.Package) error {
+	const unset = "default"
+
+	options := [][2]string{
+		{"priority", strconv.Itoa(pkg.Priority)},
+		{"clone_url", pkg.CloneURL},
+		{"schedule", schedule.Format(pkg.Schedule)},
+		{"timeout", ""},
+		{"cpu", ""},
+		{"memory", ""},
+		{"class", pkg.Class},
+	}
+
+	if pkg.Timeout > 0 {
+		options[3][1] = pkg.Timeout.String()
+	}
+
+	if pkg.Resources != nil && pkg.Resources.CPU > 0 {
+		options[4][1] = strconv.FormatFloat(pkg.Resources.CPU, 'f', -1, 64)
+	}
+
+	if pkg.Resources != nil && pkg.Resources.Memory > 0 {
+		options[5][1] = formatSize(pkg.Resources.Memory)
+	}
+
+	names := []string{}
+	for name := range pkg.Env {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		options = append(options, [2]string{envOptionPrefix + name, pkg.Env[name]})
+	}
+
+	tab := tabwriter.NewWriter(os.Stdout, 1, 2, 3, ' ', 0)
+	fmt.Fprintf(tab, "OPTION\tVALUE\n")
+
+	for _, option := range options {
+		value := option[1]
+		if value == "" {
+			value = unset
+		}
+
+		fmt.Fprintf(tab, "%s\t%s\n", option[0], value)
+	}
+
+	return tab.Flush()
+}
+
+func printFailure(failure *proto.Failure) {
+	if failure == nil {
+		return
+	}
+
+	fmt.Println()
+	fmt.Printf("last build failed: %s\n", failure.Category)
+
+	if failure.Excerpt == "" {
+		return
+	}
+
+	fmt.Println()
+
+	for _, line := range strings.Split(failure.Excerpt, "\n") {
+		fmt.Println("    " + line)
+	}
+}
+
+func formatSize(size int64) string {
+	for _, unit := range []string{"T", "G", "M", "K"} {
+		multiplier := sizeUnits[strings.ToLower(unit)]
+		if size >= multiplier && size%multiplier == 0 {
+			return fmt.Sprintf("%d%s", size/multiplier, unit)
+		}
+	}
+
+	return strconv.FormatInt(size, 10)
+}
+
+func printStages(stages []proto.Stage) error {
+	tab := tabwriter.NewWriter(os.Stdout, 1, 2, 3, ' ', 0)
+	fmt.Fprintf(tab, "REPO\tTARGET\tSTAGED\tPUBLISHED\tPROMOTED\tSTATE\n")
+
+	for _, stage := range stages {
+		promoted := stage.Promoted
+		if promoted == "" {
+			promoted = "-"
+		}
+
+		state := "soaking"
+		switch {
+		case stage.Requested:
+			state = "promotion requested"
+		case stage.Failed == stage.Version && stage.Error != "":
+			state = "failed: " + stage.Error
+		case stage.Promoted == stage.Version:
+			state = "promoted"
+		case len(stage.Problems) > 0:
+			state = "problems: " + strings.Join(stage.Problems, "; ")
+		}
+
+		fmt.Fprintf(
+			tab,
+			"%s\t%s\t%s\t%s\t%s\t%s\n",
+			stage.Repo,
+			stage.Target,
+			stage.Version,
+			stage.Published.Format(time.RFC3339),
+			promoted,
+			state,
+		)
+	}
+
+	return tab.Flush()
 }
 
 func printPackages(pkgs ...*proto.Package) error {
 	tab := tabwriter.NewWriter(os.Stdout, 1, 2, 3, ' ', 0)
-	fmt.Fprintf(tab, "NAME\tSTATUS\tVERSION\tDATE\n")
+	fmt.Fprintf(tab, "NAME\tSTATUS\tVERSION\tREPOS\tDATE\tNEXT\n")
 
 	for _, pkg := range pkgs {
+		repos := "-"
+		if len(pkg.Repos) > 0 {
+			repos = strings.Join(pkg.Repos, ",")
+		}
+
+		status := pkg.Status
+		if pkg.Failure != nil {
+			status += ": " + pkg.Failure.Category
+		}
+
+		switch {
+		case pkg.Pin != nil && pkg.Pin.Error != "":
+			status += " (pin failed: " + pkg.Pin.Error + ")"
+		case pkg.Pin != nil:
+			status += " (pinned to " + pkg.Pin.Version + ")"
+		case pkg.Frozen:
+			status += " (frozen)"
+		case pkg.Paused != nil:
+			status += " (paused by " + pkg.Paused.By + ")"
+		case pkg.Quarantined:
+			status += fmt.Sprintf(" (quarantined after %d failures)", pkg.Failures)
+		case pkg.Failures > 1:
+			status += fmt.Sprintf(" (%d failures in a row)", pkg.Failures)
+		}
+
 		fmt.Fprintf(
 			tab,
-			"%s\t%s\t%s\t%s\n",
+			"%s\t%s\t%s\t%s\t%s\t%s\n",
 			pkg.Name,
-			pkg.Status,
+			status,
 			pkg.Version,
+			repos,
 			pkg.Date.Format(time.RFC3339),
+			formatNext(pkg),
 		)
 	}
 
 	return tab.Flush()
 }
+
+// formatNext describes when the package is going to be built next time.
+func formatNext(pkg *proto.Package) string {
+	switch {
+	case pkg.Frozen:
+		return "frozen"
+	case pkg.Paused != nil:
+		return "paused"
+	case pkg.Quarantined:
+		return "on upstream change or rebuild"
+	case pkg.Next.IsZero() && pkg.Schedule != nil && pkg.Schedule.Upstream:
+		return "on upstream change"
+	case pkg.Next.IsZero():
+		return "-"
+	case pkg.Next.Before(time.Now()):
+		return "now"
+	}
+
+	return pkg.Next.Format(time.RFC3339)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/rpc"
+	"github.com/reconquest/karma-go"
+)
+
+func handleLogin(opts Options) error {
+	client := NewClient(opts.Address)
+	signer := NewSigner(opts.Key)
+
+	var response proto.ResponseIssueToken
+	err := client.Call(
+		(*rpc.AuthService).IssueToken,
+		proto.RequestIssueToken{
+			Signature: signer.sign(),
+			TTL:       opts.TTL,
+			API:       opts.API,
+		},
+		&response,
+	)
+	if err != nil {
+		return err
+	}
+
+	// API token is never put into the sign in link
+	if opts.Token || opts.API {
+		fmt.Println(response.Token)
+		return nil
+	}
+
+	base, err := url.Parse(opts.Address)
+	if err != nil {
+		return karma.Format(err, "unable to parse address")
+	}
+
+	uri := base.ResolveReference(&url.URL{
+		Path:     "/ui/login",
+		RawQuery: url.Values{"token": {response.Token}}.Encode(),
+	})
+
+	fmt.Printf(
+		"Open the following link to sign in to the web interface "+
+			"(valid until %s):\n%s\n",
+		response.Expires.Format("2006-01-02 15:04"),
+		uri.String(),
+	)
+
+	return nil
+}
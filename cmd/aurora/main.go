@@ -18,11 +18,26 @@ Aurora is a command line client for aurora daemon.
 
 Usage:
   aurora [options] get [<package>]
-  aurora [options] add <package>
+  aurora [options] add <package> [--private] [--priority <n>] [--repo <name>]... [--schedule <spec>]
+  aurora [options] set <package> <option>...
   aurora [options] rm <package>
   aurora [options] log <package>
-  aurora [options] watch <package> [-w]
+  aurora [options] rebuild <package>
+  aurora [options] promote <package> [<version>]
+  aurora [options] report <package> <problem>
+  aurora [options] pin <package> <version>
+  aurora [options] freeze <package>
+  aurora [options] unfreeze <package>
+  aurora [options] rollback <package>
+  aurora [options] queue
+  aurora [options] pause [<package>] [--drain] [--reason <text>]
+  aurora [options] resume [<package>]
+  aurora [options] status
+  aurora [options] watch <packages>... [-w]
+  aurora [options] watch --all [--status <status>]... [--glob <glob>] [--owner <name>]
   aurora [options] whoami
+  aurora [options] login [--token | --api] [--ttl <duration>]
+  aurora [options] audit [--user <name>] [--package <name>]
   aurora -h | --help
   aurora --version
 
@@ -30,10 +45,58 @@ Options:
   get                         Query specified package or query a list of packages.
   add                         Add a package to the queue.
    --clone-url <url>          Use custom clone URL of the package.
+   --private                  Hide the package from anonymous users.
+   --priority <n>             Build the package before packages with lower priority.
+   --repo <name>              Publish the package to specified repository
+                               instead of default ones.
+   --schedule <spec>          Rebuild the package by cron expression ("0 4 * * *",
+                               "@daily"), not more often than "every <interval>"
+                               ("every 6h", "every 2d") or only on "upstream"
+                               change instead of global intervals.
+  set                         Change options of a package, options are key=value:
+                               priority, clone_url, schedule, timeout (e.g. 1h),
+                               cpu (e.g. 1.5), memory (e.g. 2G), class, repos (comma
+                               separated) and env.<name>; empty value resets
+                               the option, e.g. env.MAKEFLAGS= removes variable.
   remove                      Remove a package from the queue.
   log                         Retrieve logs of a package.
-  watch                       Watch build process.
+  rebuild                     Build a package right away, lifts quarantine.
+  promote                     Promote a package from testing repository,
+                               staged version is promoted if not specified.
+  report                      Report a problem with staged version of a package,
+                               it won't be promoted automatically.
+  pin                         Publish archived build of specified version
+                               and freeze the package.
+  freeze                      Stop rebuilds of a package.
+  unfreeze                    Resume rebuilds of a package, removes the pin.
+  rollback                    Publish previous successful build and freeze
+                               the package.
+  queue                       Show running builds and packages waiting for a build
+                               in order they are dispatched, start of waiting
+                               builds and finish are estimated by previous builds;
+                               instances of waiting builds are estimated too,
+                               assuming all instances have settings of the server.
+  pause                       Stop dispatching new builds of all packages or of
+                               specified package, running builds are finished.
+   --drain                    Wait until instances stop and running builds
+                               are finished.
+   --reason <text>            Explain why the queue or the package is paused.
+  resume                      Resume builds of all packages or specified package.
+  status                      Show whether the queue is paused and running builds.
+  watch                       Watch build process of one or more packages.
+   --all                      Watch status changes and builds of all packages.
+   --status <status>          Show only events with specified status.
+   --glob <glob>              Show only events of packages matching glob.
+   --owner <name>             Show only events of packages added by specified user.
   whoami                      Retrieves information about current using in the aurora.
+  login                       Print link for signing in to the web interface.
+   --token                    Print only the token of the web interface session.
+   --api                      Print token for HTTP API instead, it allows changing
+                               packages, so never pass it in URLs.
+   --ttl <duration>           How long the token is valid, e.g. 720h [default: 12h].
+  audit                       Show who did what with packages, newest first.
+   --user <name>              Show only actions performed by specified user.
+   --package <name>           Show only actions performed on specified package.
   -a --address <rpc>          Address of aurorad rpc server. [default: https://aurora.reconquest.io/rpc/]
   -k --key <path>             Path to private RSA key. [default: $HOME/.config/aurora/id_rsa]
   --i-use-insecure-address    By default, aurora doesn't allow to use http:// schema in address.
@@ -48,16 +111,49 @@ type (
 	Options struct {
 		Get           bool
 		Add           bool
+		Set           bool
 		Rm            bool
 		Log           bool
+		Rebuild       bool
+		Promote       bool
+		Report        bool
+		Pin           bool
+		Freeze        bool
+		Unfreeze      bool
+		Rollback      bool
+		Queue         bool
+		Pause         bool
+		Resume        bool
+		Drain         bool
+		Reason        string `docopt:"--reason"`
+		ShowStatus    bool   `docopt:"status"`
+		Version       string `docopt:"<version>"`
+		Problem       string `docopt:"<problem>"`
 		Watch         bool
 		Whoami        bool
+		Login         bool
+		Token         bool
+		API           bool   `docopt:"--api"`
+		TTL           string `docopt:"--ttl"`
+		Audit         bool
 		Address       string
 		Package       string
+		Packages      []string
 		Key           string
 		AllowInsecure bool `docopt:"--i-use-insecure-address"`
 		Wait          bool
 		CloneURL      string `docopt:"--clone-url"`
+		Private       bool
+		Priority      string   `docopt:"--priority"`
+		Option        []string `docopt:"<option>"`
+		Repo          []string `docopt:"--repo"`
+		Schedule      string   `docopt:"--schedule"`
+		User          string   `docopt:"--user"`
+		AuditPackage  string   `docopt:"--package"`
+		All           bool
+		Status        []string
+		Glob          string
+		Owner         string
 	}
 )
 
@@ -86,14 +182,42 @@ func main() {
 		err = handleGet(opts)
 	case opts.Add:
 		err = handleAdd(opts)
+	case opts.Set:
+		err = handleSet(opts)
 	case opts.Rm:
 		err = handleRemove(opts)
 	case opts.Log:
 		err = handleLog(opts)
+	case opts.Rebuild:
+		err = handleRebuild(opts)
+	case opts.Promote:
+		err = handlePromote(opts)
+	case opts.Report:
+		err = handleReport(opts)
+	case opts.Pin:
+		err = handlePin(opts)
+	case opts.Freeze:
+		err = handleFreeze(opts, true)
+	case opts.Unfreeze:
+		err = handleFreeze(opts, false)
+	case opts.Rollback:
+		err = handleRollback(opts)
+	case opts.Queue:
+		err = handleQueue(opts)
+	case opts.Pause:
+		err = handlePause(opts, true)
+	case opts.Resume:
+		err = handlePause(opts, false)
+	case opts.ShowStatus:
+		err = handleStatus(opts)
 	case opts.Watch:
 		err = handleWatch(opts)
 	case opts.Whoami:
 		err = handleWhoami(opts)
+	case opts.Login:
+		err = handleLogin(opts)
+	case opts.Audit:
+		err = handleAudit(opts)
 	}
 
 	if err != nil {
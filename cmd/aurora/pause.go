@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/rpc"
+	"github.com/kovetskiy/aurora/pkg/signature"
+)
+
+const drainInterval = 5 * time.Second
+
+func handlePause(opts Options, paused bool) error {
+	if opts.Package != "" {
+		return handlePausePackage(opts, paused)
+	}
+
+	client := NewClient(opts.Address)
+	signer := NewSigner(opts.Key)
+
+	err := client.Call(
+		(*rpc.AdminService).PauseQueue,
+		proto.RequestPauseQueue{
+			Signature: signer.sign(),
+			Paused:    paused,
+			Reason:    opts.Reason,
+		},
+		&proto.ResponsePauseQueue{},
+	)
+	if err != nil {
+		return err
+	}
+
+	if !paused {
+		fmt.Println("queue has been resumed")
+		return nil
+	}
+
+	fmt.Println("queue has been paused")
+
+	if !opts.Drain {
+		return nil
+	}
+
+	return drain(opts)
+}
+
+func handlePausePackage(opts Options, paused bool) error {
+	client := NewClient(opts.Address)
+	signer := NewSigner(opts.Key)
+
+	err := client.Call(
+		(*rpc.PackageService).PausePackage,
+		proto.RequestPausePackage{
+			Signature: signer.sign(),
+			Name:      opts.Package,
+			Paused:    paused,
+			Reason:    opts.Reason,
+		},
+		&proto.ResponsePausePackage{},
+	)
+	if err != nil {
+		return err
+	}
+
+	if paused {
+		fmt.Println("package has been paused")
+	} else {
+		fmt.Println("package has been resumed")
+	}
+
+	return nil
+}
+
+// drain waits until every processor has stopped dispatching builds and
+// builds which were running when the queue was paused are finished.
+func drain(opts Options) error {
+	client := NewClient(opts.Address)
+	signer := NewSigner(opts.Key)
+
+	last := ""
+	for {
+		status, err := getStatus(client, signer.sign())
+		if err != nil {
+			return err
+		}
+
+		if status.Pause == nil {
+			return fmt.Errorf("queue has been resumed while draining")
+		}
+
+		if len(status.Running) == 0 && len(status.Dispatching) == 0 {
+			fmt.Println("queue has been drained")
+			return nil
+		}
+
+		waiting := fmt.Sprintf(
+			"waiting for %d running build(s): %s",
+			len(status.Running), strings.Join(status.Running, ", "),
+		)
+		if len(status.Dispatching) > 0 {
+			waiting += "; instances to stop: " +
+				strings.Join(status.Dispatching, ", ")
+		}
+
+		if waiting != last {
+			fmt.Println(waiting)
+			last = waiting
+		}
+
+		time.Sleep(drainInterval)
+	}
+}
+
+func handleStatus(opts Options) error {
+	client := NewClient(opts.Address)
+	signer := NewSigner(opts.Key)
+
+	status, err := getStatus(client, signer.sign())
+	if err != nil {
+		return err
+	}
+
+	printPause(status.Pause)
+
+	if len(status.Running) == 0 {
+		fmt.Println("running: -")
+	} else {
+		fmt.Println("running: " + strings.Join(status.Running, ", "))
+	}
+
+	if len(status.Dispatching) > 0 {
+		fmt.Println("dispatching: " + strings.Join(status.Dispatching, ", "))
+	}
+
+	return nil
+}
+
+func getStatus(client *Client, signature *signature.Signature) (*proto.ResponseGetStatus, error) {
+	var reply proto.ResponseGetStatus
+	err := client.Call(
+		(*rpc.AdminService).GetStatus,
+		proto.RequestGetStatus{
+			Signature: signature,
+		},
+		&reply,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reply, nil
+}
+
+func printPause(pause *proto.Pause) {
+	if pause == nil {
+		fmt.Println("queue: running")
+		return
+	}
+
+	fmt.Printf(
+		"queue: paused by %s since %s\n",
+		pause.By, pause.Since.Format(time.RFC3339),
+	)
+
+	if pause.Reason != "" {
+		fmt.Println("reason: " + pause.Reason)
+	}
+}
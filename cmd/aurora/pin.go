@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/rpc"
+)
+
+func handlePin(opts Options) error {
+	client := NewClient(opts.Address)
+	signer := NewSigner(opts.Key)
+
+	err := client.Call(
+		(*rpc.PackageService).PinPackage,
+		proto.RequestPinPackage{
+			Signature: signer.sign(),
+			Name:      opts.Package,
+			Version:   opts.Version,
+		},
+		&proto.ResponsePinPackage{},
+	)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("package has been pinned to %s and frozen\n", opts.Version)
+
+	return nil
+}
+
+func handleFreeze(opts Options, frozen bool) error {
+	client := NewClient(opts.Address)
+	signer := NewSigner(opts.Key)
+
+	err := client.Call(
+		(*rpc.PackageService).FreezePackage,
+		proto.RequestFreezePackage{
+			Signature: signer.sign(),
+			Name:      opts.Package,
+			Frozen:    frozen,
+		},
+		&proto.ResponseFreezePackage{},
+	)
+	if err != nil {
+		return err
+	}
+
+	if frozen {
+		fmt.Println("package has been frozen")
+	} else {
+		fmt.Println("package has been unfrozen")
+	}
+
+	return nil
+}
+
+func handleRollback(opts Options) error {
+	client := NewClient(opts.Address)
+	signer := NewSigner(opts.Key)
+
+	var reply proto.ResponseRollbackPackage
+
+	err := client.Call(
+		(*rpc.PackageService).RollbackPackage,
+		proto.RequestRollbackPackage{
+			Signature: signer.sign(),
+			Name:      opts.Package,
+		},
+		&reply,
+	)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("package has been rolled back to %s and frozen\n", reply.Version)
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/rpc"
+)
+
+func handlePromote(opts Options) error {
+	client := NewClient(opts.Address)
+	signer := NewSigner(opts.Key)
+
+	err := client.Call(
+		(*rpc.PackageService).PromotePackage,
+		proto.RequestPromotePackage{
+			Signature: signer.sign(),
+			Name:      opts.Package,
+			Version:   opts.Version,
+		},
+		&proto.ResponsePromotePackage{},
+	)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("package will be promoted shortly")
+
+	return nil
+}
+
+func handleReport(opts Options) error {
+	client := NewClient(opts.Address)
+	signer := NewSigner(opts.Key)
+
+	err := client.Call(
+		(*rpc.PackageService).ReportProblem,
+		proto.RequestReportProblem{
+			Signature: signer.sign(),
+			Name:      opts.Package,
+			Problem:   opts.Problem,
+		},
+		&proto.ResponseReportProblem{},
+	)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("problem has been reported, staged version won't be promoted automatically")
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/rpc"
+)
+
+func handleQueue(opts Options) error {
+	client := NewClient(opts.Address)
+	signer := NewSigner(opts.Key)
+
+	var response proto.ResponseGetQueue
+	err := client.Call(
+		(*rpc.PackageService).GetQueue,
+		proto.RequestGetQueue{
+			Signature: signer.sign(),
+		},
+		&response,
+	)
+	if err != nil {
+		return err
+	}
+
+	tab := tabwriter.NewWriter(os.Stdout, 1, 2, 3, ' ', 0)
+	fmt.Fprintf(tab, "NAME\tSTATUS\tPRIORITY\tCLASS\tWAITING\tINSTANCE\tSTART\tFINISH\n")
+
+	for _, item := range response.Queue {
+		waiting := "-"
+		if item.Waiting > 0 {
+			waiting = item.Waiting.Round(time.Second).String()
+		}
+
+		priority := strconv.Itoa(item.Priority)
+		if item.Effective != item.Priority {
+			priority += fmt.Sprintf(" (%+d)", item.Effective-item.Priority)
+		}
+
+		class := item.Class
+		if class == "" {
+			class = "-"
+		}
+
+		// without history of the package the finish can't be estimated
+		finish := "?"
+		if item.Duration > 0 {
+			finish = item.Finish.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(
+			tab,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			item.Package,
+			item.Status,
+			priority,
+			class,
+			waiting,
+			item.Instance,
+			item.Start.Format(time.RFC3339),
+			finish,
+		)
+	}
+
+	return tab.Flush()
+}
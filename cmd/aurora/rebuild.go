@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/rpc"
+)
+
+func handleRebuild(opts Options) error {
+	client := NewClient(opts.Address)
+	signer := NewSigner(opts.Key)
+
+	err := client.Call(
+		(*rpc.PackageService).RebuildPackage,
+		proto.RequestRebuildPackage{
+			Signature: signer.sign(),
+			Name:      opts.Package,
+		},
+		&proto.ResponseRebuildPackage{},
+	)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("package has been queued for rebuild")
+
+	return nil
+}
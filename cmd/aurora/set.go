@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/rpc"
+	"github.com/kovetskiy/aurora/pkg/schedule"
+	"github.com/reconquest/karma-go"
+)
+
+const envOptionPrefix = "env."
+
+var sizeUnits = map[string]int64{
+	"":  1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+}
+
+func handleSet(opts Options) error {
+	request := proto.RequestUpdatePackage{
+		Name: opts.Package,
+	}
+
+	for _, option := range opts.Option {
+		err := parseOption(&request, option)
+		if err != nil {
+			return karma.Format(err, "invalid option %q", option)
+		}
+	}
+
+	client := NewClient(opts.Address)
+	signer := NewSigner(opts.Key)
+
+	request.Signature = signer.sign()
+
+	err := client.Call(
+		(*rpc.PackageService).UpdatePackage,
+		request,
+		&proto.ResponseUpdatePackage{},
+	)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("package has been updated")
+
+	return nil
+}
+
+// parseOption parses key=value and sets corresponding field of the request,
+// empty value resets the option to default.
+func parseOption(request *proto.RequestUpdatePackage, option string) error {
+	chunks := strings.SplitN(option, "=", 2)
+	if len(chunks) != 2 {
+		return errors.New("should be in form key=value")
+	}
+
+	key, value := chunks[0], chunks[1]
+
+	if strings.HasPrefix(key, envOptionPrefix) {
+		name := strings.TrimPrefix(key, envOptionPrefix)
+		if !proto.IsValidEnvName(name) {
+			return errors.New("invalid or reserved environment variable name")
+		}
+
+		if request.Env == nil {
+			request.Env = map[string]string{}
+		}
+
+		request.Env[name] = value
+
+		return nil
+	}
+
+	switch key {
+	case "priority":
+		priority := 0
+		if value != "" {
+			var err error
+
+			priority, err = strconv.Atoi(value)
+			if err != nil {
+				return errors.New("priority should be an integer")
+			}
+		}
+
+		request.Priority = &priority
+
+	case "clone_url":
+		if value != "" && !proto.IsValidCloneURL(value) {
+			return errors.New("clone url should be http(s), git or ssh url")
+		}
+
+		request.CloneURL = &value
+
+	case "schedule":
+		_, err := schedule.Parse(value)
+		if err != nil {
+			return err
+		}
+
+		request.Schedule = &value
+
+	case "timeout":
+		var timeout time.Duration
+		if value != "" {
+			var err error
+
+			timeout, err = time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+		}
+
+		request.Timeout = &timeout
+
+	case "cpu":
+		var cpu float64
+		if value != "" {
+			var err error
+
+			cpu, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				return errors.New("cpu should be a number, e.g. 1.5")
+			}
+		}
+
+		request.CPU = &cpu
+
+	case "memory":
+		memory, err := parseSize(value)
+		if err != nil {
+			return err
+		}
+
+		request.Memory = &memory
+
+	case "class":
+		request.Class = &value
+
+	case "repos":
+		repos := []string{}
+		if value != "" {
+			repos = strings.Split(value, ",")
+		}
+
+		request.Repos = &repos
+
+	default:
+		return errors.New(
+			"unknown option, available are: priority, clone_url, schedule, " +
+				"timeout, cpu, memory, class, repos and env.<name>",
+		)
+	}
+
+	return nil
+}
+
+// parseSize parses size like 512M or 2G in binary units.
+func parseSize(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	number := strings.TrimRight(value, "kKmMgGtTiIbB")
+
+	// 2G, 2GB and 2GiB are the same
+	unit := strings.ToLower(value[len(number):])
+	unit = strings.TrimSuffix(unit, "b")
+	unit = strings.TrimSuffix(unit, "i")
+
+	multiplier, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit: %q", value[len(number):])
+	}
+
+	size, err := strconv.ParseFloat(number, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size: %q", value)
+	}
+
+	return int64(size * float64(multiplier)), nil
+}
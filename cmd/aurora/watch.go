@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/kovetskiy/aurora/pkg/bus"
@@ -12,16 +16,108 @@ import (
 	"github.com/reconquest/karma-go"
 )
 
+// watchOutput prints messages of a single package, if prefix is given then
+// every line is prefixed, so output of several packages can be mixed.
+type watchOutput struct {
+	mutex  *sync.Mutex
+	prefix string
+	buffer string
+}
+
+func (output *watchOutput) log(data string) {
+	if output.prefix == "" {
+		fmt.Print(data)
+		return
+	}
+
+	output.buffer += data
+
+	lines := strings.Split(output.buffer, "\n")
+	output.buffer = lines[len(lines)-1]
+
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+
+	for _, line := range lines[:len(lines)-1] {
+		fmt.Printf("%s %s\n", output.prefix, line)
+	}
+}
+
+func (output *watchOutput) status(status string) {
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+
+	if output.prefix == "" {
+		fmt.Printf("Status: %s\n", status)
+	} else {
+		fmt.Printf("%s Status: %s\n", output.prefix, status)
+	}
+}
+
 func handleWatch(opts Options) error {
 	client := NewClient(opts.Address)
 	signer := NewSigner(opts.Key)
 
+	if opts.All {
+		return watchEvents(client, signer, opts)
+	}
+
+	mutex := &sync.Mutex{}
+
+	if len(opts.Packages) == 1 {
+		return watchPackage(
+			client, signer, opts, opts.Packages[0],
+			&watchOutput{mutex: mutex},
+		)
+	}
+
+	width := 0
+	for _, name := range opts.Packages {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	errs := make(chan error, len(opts.Packages))
+	for _, name := range opts.Packages {
+		output := &watchOutput{
+			mutex:  mutex,
+			prefix: fmt.Sprintf("[%-*s]", width, name),
+		}
+
+		go func(name string) {
+			err := watchPackage(client, signer, opts, name, output)
+			if err != nil {
+				err = karma.Format(err, "unable to watch %s", name)
+			}
+
+			errs <- err
+		}(name)
+	}
+
+	for range opts.Packages {
+		err := <-errs
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func watchPackage(
+	client *Client,
+	signer *signer,
+	opts Options,
+	name string,
+	output *watchOutput,
+) error {
 	var response proto.ResponseGetBus
 	err := client.Call(
 		(*rpc.PackageService).GetBus,
 		proto.RequestGetBus{
 			Signature: signer.sign(),
-			Name:      opts.Package,
+			Name:      name,
 		},
 		&response,
 	)
@@ -29,22 +125,13 @@ func handleWatch(opts Options) error {
 		return err
 	}
 
-	stream := response.Stream
-
-	connection, _, err := websocket.DefaultDialer.Dial(
-		stream, nil,
-	)
+	connection, err := connectStream(opts.Address, response.Stream, nil)
 	if err != nil {
-		return karma.Format(
-			err,
-			"unable to connect to logs stream: %s", stream,
-		)
+		return err
 	}
 
 	defer connection.Close()
 
-	log.Printf("connected to logs stream: %s", stream)
-
 	var message bus.Message
 	for {
 		_, reader, err := connection.NextReader()
@@ -58,20 +145,177 @@ func handleWatch(opts Options) error {
 		}
 
 		switch message.Type {
-		case "status":
+		case bus.MessageTypeStatus:
 			status := message.Data.(string)
-			fmt.Printf("Status: %s\n", status)
+			output.status(status)
 			if opts.Wait {
-				if status == "success" || status == "failure" {
+				if status == "success" || status == "failure" ||
+					status == "cancelled" {
 					return nil
 				}
 			}
-		case "log":
-			fmt.Print(message.Data)
+		case bus.MessageTypeLog:
+			output.log(message.Data.(string))
+		case bus.MessageTypeEmptyChannel:
+			log.Printf("%s: no builds since the daemon start", name)
+		case bus.MessageTypeDropped:
+			log.Printf(
+				"%s: %v messages were dropped, client is too slow",
+				name, message.Data,
+			)
 		default:
 			log.Printf("unhandled type of message: %q", message.Type)
 		}
 	}
+}
 
-	return nil
+func watchEvents(client *Client, signer *signer, opts Options) error {
+	var response proto.ResponseGetBus
+	err := client.Call(
+		(*rpc.PackageService).GetBus,
+		proto.RequestGetBus{
+			Signature: signer.sign(),
+			All:       true,
+		},
+		&response,
+	)
+	if err != nil {
+		return err
+	}
+
+	filter := url.Values{}
+	if len(opts.Status) > 0 {
+		filter["status"] = opts.Status
+	}
+
+	if opts.Glob != "" {
+		filter.Set("glob", opts.Glob)
+	}
+
+	if opts.Owner != "" {
+		filter.Set("owner", opts.Owner)
+	}
+
+	connection, err := connectStream(opts.Address, response.Stream, filter)
+	if err != nil {
+		return err
+	}
+
+	defer connection.Close()
+
+	var message struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+
+	for {
+		_, reader, err := connection.NextReader()
+		if err != nil {
+			return err
+		}
+
+		err = json.NewDecoder(reader).Decode(&message)
+		if err != nil {
+			return err
+		}
+
+		switch message.Type {
+		case bus.MessageTypeEvent:
+			var event proto.Event
+
+			err = json.Unmarshal(message.Data, &event)
+			if err != nil {
+				return err
+			}
+
+			printEvent(event)
+		case bus.MessageTypeDropped:
+			log.Printf("%s events were dropped, client is too slow", message.Data)
+		case bus.MessageTypeEmptyChannel:
+		default:
+			log.Printf("unhandled type of message: %q", message.Type)
+		}
+	}
+}
+
+func printEvent(event proto.Event) {
+	details := event.Type
+
+	switch event.Type {
+	case proto.EventStatus:
+		details = "status: " + event.Status
+	case proto.EventBuildStarted:
+		details = "build started at " + event.Instance
+	case proto.EventBuildFinished:
+		details = fmt.Sprintf(
+			"build finished: %s in %s",
+			event.Status, event.Duration.Round(time.Second),
+		)
+	}
+
+	fmt.Printf(
+		"%s [%s] %s\n",
+		event.Time.Format("15:04:05"), event.Package, details,
+	)
+}
+
+func connectStream(
+	address string,
+	stream string,
+	params url.Values,
+) (*websocket.Conn, error) {
+	stream, err := resolveStream(address, stream, params)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, _, err := websocket.DefaultDialer.Dial(
+		stream, nil,
+	)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to connect to logs stream: %s", stream,
+		)
+	}
+
+	log.Printf("connected to logs stream: %s", stream)
+
+	return connection, nil
+}
+
+// resolveStream returns websocket address of the stream, the stream is
+// usually given relative to the address of rpc server.
+func resolveStream(
+	address string,
+	stream string,
+	params url.Values,
+) (string, error) {
+	base, err := url.Parse(address)
+	if err != nil {
+		return "", karma.Format(err, "unable to parse address")
+	}
+
+	reference, err := url.Parse(stream)
+	if err != nil {
+		return "", karma.Format(err, "unable to parse stream address")
+	}
+
+	uri := base.ResolveReference(reference)
+
+	switch uri.Scheme {
+	case "https":
+		uri.Scheme = "wss"
+	case "http":
+		uri.Scheme = "ws"
+	}
+
+	query := uri.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+
+	uri.RawQuery = query.Encode()
+
+	return uri.String(), nil
 }
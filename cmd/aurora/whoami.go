@@ -25,9 +25,17 @@ func handleWhoami(opts Options) error {
 
 	if response.Name == "" {
 		fmt.Println("Unauthorized")
-	} else {
-		fmt.Println(response.Name)
+		return nil
 	}
 
+	fmt.Println(response.Name)
+
+	status, err := getStatus(client, signer.sign())
+	if err != nil {
+		return err
+	}
+
+	printPause(status.Pause)
+
 	return nil
 }
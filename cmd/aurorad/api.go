@@ -0,0 +1,529 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/rpc"
+	"github.com/kovetskiy/aurora/pkg/signature"
+	"github.com/kovetskiy/aurora/pkg/staging"
+)
+
+const apiPrefix = "/api/v1"
+
+// API exposes PackageService and AdminService as resource-oriented HTTP
+// endpoints. Requests are authenticated by signature in X-Aurora-Signature
+// header or by API token issued by AuthService.IssueToken in
+// "Authorization: Bearer" header, session tokens of the web interface are
+// not accepted. Requests without both are anonymous.
+type API struct {
+	packages *rpc.PackageService
+	admin    *rpc.AdminService
+	auth     *rpc.AuthService
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func NewAPI(
+	packages *rpc.PackageService,
+	admin *rpc.AdminService,
+	auth *rpc.AuthService,
+) *API {
+	return &API{
+		packages: packages,
+		admin:    admin,
+		auth:     auth,
+	}
+}
+
+func (api *API) Route(router chi.Router) {
+	router.Get("/openapi.json", api.serveOpenAPI)
+
+	router.Group(func(router chi.Router) {
+		router.Use(api.authenticate)
+
+		router.Get("/packages", api.listPackages)
+		router.Post("/packages", api.addPackage)
+		router.Get("/packages/{name}", api.getPackage)
+		router.Patch("/packages/{name}", api.updatePackage)
+		router.Delete("/packages/{name}", api.removePackage)
+		router.Get("/packages/{name}/logs", api.getLogs)
+		router.Get("/packages/{name}/builds", api.listBuilds)
+		router.Post("/packages/{name}/rebuild", api.rebuildPackage)
+		router.Post("/packages/{name}/promote", api.promotePackage)
+		router.Post("/packages/{name}/problems", api.reportProblem)
+		router.Post("/packages/{name}/pin", api.pinPackage)
+		router.Post("/packages/{name}/freeze", api.freezePackage(true))
+		router.Post("/packages/{name}/unfreeze", api.freezePackage(false))
+		router.Post("/packages/{name}/rollback", api.rollbackPackage)
+		router.Post("/packages/{name}/pause", api.pausePackage(true))
+		router.Post("/packages/{name}/resume", api.pausePackage(false))
+		router.Get("/queue", api.getQueue)
+		router.Post("/queue/pause", api.pauseQueue(true))
+		router.Post("/queue/resume", api.pauseQueue(false))
+		router.Get("/status", api.getStatus)
+	})
+}
+
+func (api *API) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(response http.ResponseWriter, request *http.Request) {
+			if request.Header.Get("Authorization") != "" {
+				token := strings.TrimPrefix(
+					request.Header.Get("Authorization"),
+					"Bearer ",
+				)
+
+				signer := api.auth.VerifyAPIToken(token)
+				if signer == nil {
+					api.fail(response, rpc.ErrorInvalidToken)
+					return
+				}
+
+				request = rpc.WithSigner(request, signer)
+			} else if request.Header.Get(signature.Header) != "" {
+				signer := api.auth.VerifyRequest(request)
+				if signer == nil {
+					api.fail(response, rpc.ErrorUnauthorized)
+					return
+				}
+
+				request = rpc.WithSigner(request, signer)
+			}
+
+			next.ServeHTTP(response, request)
+		},
+	)
+}
+
+func (api *API) listPackages(response http.ResponseWriter, request *http.Request) {
+	var reply proto.ResponseListPackages
+
+	err := api.packages.ListPackages(
+		request,
+		&proto.RequestListPackages{},
+		&reply,
+	)
+	if err != nil {
+		api.fail(response, err)
+		return
+	}
+
+	api.respond(response, http.StatusOK, reply)
+}
+
+func (api *API) getPackage(response http.ResponseWriter, request *http.Request) {
+	var reply proto.ResponseGetPackage
+
+	err := api.packages.GetPackage(
+		request,
+		&proto.RequestGetPackage{Name: chi.URLParam(request, "name")},
+		&reply,
+	)
+	if err != nil {
+		api.fail(response, err)
+		return
+	}
+
+	if reply.Package == nil {
+		api.fail(response, rpc.ErrorNoSuchPackage)
+		return
+	}
+
+	api.respond(response, http.StatusOK, reply)
+}
+
+func (api *API) addPackage(response http.ResponseWriter, request *http.Request) {
+	var payload proto.RequestAddPackage
+
+	err := json.NewDecoder(request.Body).Decode(&payload)
+	if err != nil {
+		api.respond(
+			response,
+			http.StatusBadRequest,
+			apiError{Error: "unable to decode request body: " + err.Error()},
+		)
+		return
+	}
+
+	// signature is passed in header, not in the body
+	payload.Signature = nil
+
+	var reply proto.ResponseAddPackage
+
+	err = api.packages.AddPackage(request, &payload, &reply)
+	if err != nil {
+		api.fail(response, err)
+		return
+	}
+
+	api.respond(response, http.StatusCreated, reply)
+}
+
+func (api *API) updatePackage(response http.ResponseWriter, request *http.Request) {
+	var payload proto.RequestUpdatePackage
+
+	err := json.NewDecoder(request.Body).Decode(&payload)
+	if err != nil {
+		api.respond(
+			response,
+			http.StatusBadRequest,
+			apiError{Error: "unable to decode request body: " + err.Error()},
+		)
+		return
+	}
+
+	payload.Signature = nil
+	payload.Name = chi.URLParam(request, "name")
+
+	var reply proto.ResponseUpdatePackage
+
+	err = api.packages.UpdatePackage(request, &payload, &reply)
+	if err != nil {
+		api.fail(response, err)
+		return
+	}
+
+	api.respond(response, http.StatusOK, reply)
+}
+
+func (api *API) removePackage(response http.ResponseWriter, request *http.Request) {
+	var reply proto.ResponseRemovePackage
+
+	err := api.packages.RemovePackage(
+		request,
+		&proto.RequestRemovePackage{Name: chi.URLParam(request, "name")},
+		&reply,
+	)
+	if err != nil {
+		api.fail(response, err)
+		return
+	}
+
+	api.respond(response, http.StatusOK, reply)
+}
+
+func (api *API) getLogs(response http.ResponseWriter, request *http.Request) {
+	var reply proto.ResponseGetLogs
+
+	err := api.packages.GetLogs(
+		request,
+		&proto.RequestGetLogs{Name: chi.URLParam(request, "name")},
+		&reply,
+	)
+	if err != nil {
+		api.fail(response, err)
+		return
+	}
+
+	response.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	response.Write([]byte(reply.Logs))
+}
+
+func (api *API) listBuilds(response http.ResponseWriter, request *http.Request) {
+	limit, _ := strconv.Atoi(request.URL.Query().Get("limit"))
+
+	var reply proto.ResponseListBuilds
+
+	err := api.packages.ListBuilds(
+		request,
+		&proto.RequestListBuilds{
+			Name:  chi.URLParam(request, "name"),
+			Limit: limit,
+		},
+		&reply,
+	)
+	if err != nil {
+		api.fail(response, err)
+		return
+	}
+
+	api.respond(response, http.StatusOK, reply)
+}
+
+func (api *API) getQueue(response http.ResponseWriter, request *http.Request) {
+	var reply proto.ResponseGetQueue
+
+	err := api.packages.GetQueue(
+		request,
+		&proto.RequestGetQueue{},
+		&reply,
+	)
+	if err != nil {
+		api.fail(response, err)
+		return
+	}
+
+	api.respond(response, http.StatusOK, reply)
+}
+
+func (api *API) rebuildPackage(response http.ResponseWriter, request *http.Request) {
+	var reply proto.ResponseRebuildPackage
+
+	err := api.packages.RebuildPackage(
+		request,
+		&proto.RequestRebuildPackage{Name: chi.URLParam(request, "name")},
+		&reply,
+	)
+	if err != nil {
+		api.fail(response, err)
+		return
+	}
+
+	api.respond(response, http.StatusAccepted, reply)
+}
+
+func (api *API) promotePackage(response http.ResponseWriter, request *http.Request) {
+	var payload proto.RequestPromotePackage
+
+	// body is optional, the staged version is promoted without it
+	if request.ContentLength != 0 {
+		err := json.NewDecoder(request.Body).Decode(&payload)
+		if err != nil {
+			api.respond(
+				response,
+				http.StatusBadRequest,
+				apiError{Error: "unable to decode request body: " + err.Error()},
+			)
+			return
+		}
+	}
+
+	payload.Signature = nil
+	payload.Name = chi.URLParam(request, "name")
+
+	var reply proto.ResponsePromotePackage
+
+	err := api.packages.PromotePackage(request, &payload, &reply)
+	if err != nil {
+		api.fail(response, err)
+		return
+	}
+
+	api.respond(response, http.StatusAccepted, reply)
+}
+
+func (api *API) reportProblem(response http.ResponseWriter, request *http.Request) {
+	var payload proto.RequestReportProblem
+
+	err := json.NewDecoder(request.Body).Decode(&payload)
+	if err != nil {
+		api.respond(
+			response,
+			http.StatusBadRequest,
+			apiError{Error: "unable to decode request body: " + err.Error()},
+		)
+		return
+	}
+
+	payload.Signature = nil
+	payload.Name = chi.URLParam(request, "name")
+
+	var reply proto.ResponseReportProblem
+
+	err = api.packages.ReportProblem(request, &payload, &reply)
+	if err != nil {
+		api.fail(response, err)
+		return
+	}
+
+	api.respond(response, http.StatusCreated, reply)
+}
+
+func (api *API) pinPackage(response http.ResponseWriter, request *http.Request) {
+	var payload proto.RequestPinPackage
+
+	err := json.NewDecoder(request.Body).Decode(&payload)
+	if err != nil {
+		api.respond(
+			response,
+			http.StatusBadRequest,
+			apiError{Error: "unable to decode request body: " + err.Error()},
+		)
+		return
+	}
+
+	payload.Signature = nil
+	payload.Name = chi.URLParam(request, "name")
+
+	var reply proto.ResponsePinPackage
+
+	err = api.packages.PinPackage(request, &payload, &reply)
+	if err != nil {
+		api.fail(response, err)
+		return
+	}
+
+	api.respond(response, http.StatusAccepted, reply)
+}
+
+func (api *API) freezePackage(frozen bool) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		var reply proto.ResponseFreezePackage
+
+		err := api.packages.FreezePackage(
+			request,
+			&proto.RequestFreezePackage{
+				Name:   chi.URLParam(request, "name"),
+				Frozen: frozen,
+			},
+			&reply,
+		)
+		if err != nil {
+			api.fail(response, err)
+			return
+		}
+
+		api.respond(response, http.StatusOK, reply)
+	}
+}
+
+func (api *API) rollbackPackage(response http.ResponseWriter, request *http.Request) {
+	var reply proto.ResponseRollbackPackage
+
+	err := api.packages.RollbackPackage(
+		request,
+		&proto.RequestRollbackPackage{Name: chi.URLParam(request, "name")},
+		&reply,
+	)
+	if err != nil {
+		api.fail(response, err)
+		return
+	}
+
+	api.respond(response, http.StatusAccepted, reply)
+}
+
+func (api *API) pausePackage(paused bool) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		var payload proto.RequestPausePackage
+
+		// body is optional, it only carries the reason
+		if request.ContentLength != 0 {
+			err := json.NewDecoder(request.Body).Decode(&payload)
+			if err != nil {
+				api.respond(
+					response,
+					http.StatusBadRequest,
+					apiError{Error: "unable to decode request body: " + err.Error()},
+				)
+				return
+			}
+		}
+
+		payload.Signature = nil
+		payload.Name = chi.URLParam(request, "name")
+		payload.Paused = paused
+
+		var reply proto.ResponsePausePackage
+
+		err := api.packages.PausePackage(request, &payload, &reply)
+		if err != nil {
+			api.fail(response, err)
+			return
+		}
+
+		api.respond(response, http.StatusOK, reply)
+	}
+}
+
+func (api *API) pauseQueue(paused bool) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		var payload proto.RequestPauseQueue
+
+		// body is optional, it only carries the reason
+		if request.ContentLength != 0 {
+			err := json.NewDecoder(request.Body).Decode(&payload)
+			if err != nil {
+				api.respond(
+					response,
+					http.StatusBadRequest,
+					apiError{Error: "unable to decode request body: " + err.Error()},
+				)
+				return
+			}
+		}
+
+		payload.Signature = nil
+		payload.Paused = paused
+
+		var reply proto.ResponsePauseQueue
+
+		err := api.admin.PauseQueue(request, &payload, &reply)
+		if err != nil {
+			api.fail(response, err)
+			return
+		}
+
+		api.respond(response, http.StatusOK, reply)
+	}
+}
+
+func (api *API) getStatus(response http.ResponseWriter, request *http.Request) {
+	var reply proto.ResponseGetStatus
+
+	err := api.admin.GetStatus(
+		request,
+		&proto.RequestGetStatus{},
+		&reply,
+	)
+	if err != nil {
+		api.fail(response, err)
+		return
+	}
+
+	api.respond(response, http.StatusOK, reply)
+}
+
+func (api *API) serveOpenAPI(response http.ResponseWriter, request *http.Request) {
+	response.Header().Set("Content-Type", "application/json")
+	response.Write([]byte(apiOpenAPI))
+}
+
+func (api *API) respond(
+	response http.ResponseWriter,
+	status int,
+	data interface{},
+) {
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(status)
+
+	err := json.NewEncoder(response).Encode(data)
+	if err != nil {
+		errorh(err, "unable to write API response")
+	}
+}
+
+// fail responds with status code corresponding to the error returned by
+// PackageService.
+func (api *API) fail(response http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	switch err {
+	case rpc.ErrorUnauthorized, rpc.ErrorInvalidToken:
+		status = http.StatusUnauthorized
+	case rpc.ErrorNoSuchPackage:
+		status = http.StatusNotFound
+	case rpc.ErrorInvalidPackageName, rpc.ErrorNoSuchRepository,
+		rpc.ErrorInvalidVersion, rpc.ErrorInvalidSchedule,
+		rpc.ErrorInvalidCloneURL, rpc.ErrorInvalidTimeout,
+		rpc.ErrorInvalidResources, rpc.ErrorInvalidEnv,
+		rpc.ErrorNoSuchClass:
+		status = http.StatusBadRequest
+	case rpc.ErrorPackageIsBuilding, staging.ErrorNotStaged,
+		rpc.ErrorNoPreviousBuild:
+		status = http.StatusConflict
+	}
+
+	if status == http.StatusInternalServerError {
+		errorh(err, "API request failed")
+	}
+
+	api.respond(response, status, apiError{Error: err.Error()})
+}
@@ -0,0 +1,479 @@
+package main
+
+// apiOpenAPI describes API served under apiPrefix, it's published at
+// /api/v1/openapi.json.
+const apiOpenAPI = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "aurora",
+    "version": "1",
+    "description": "Read and manage packages built by aurora. Requests are authenticated by X-Aurora-Signature header or by bearer token printed by 'aurora login --token', requests without both are anonymous."
+  },
+  "servers": [{"url": "/api/v1"}],
+  "security": [{"bearer": []}, {"signature": []}, {}],
+  "paths": {
+    "/packages": {
+      "get": {
+        "summary": "List packages",
+        "responses": {
+          "200": {
+            "description": "Packages visible to the caller",
+            "content": {"application/json": {"schema": {
+              "type": "object",
+              "properties": {
+                "packages": {"type": "array", "items": {"$ref": "#/components/schemas/Package"}}
+              }
+            }}}
+          },
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Add package to the queue",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {
+            "type": "object",
+            "required": ["name"],
+            "properties": {
+              "name": {"type": "string"},
+              "clone_url": {"type": "string"},
+              "private": {"type": "boolean"},
+              "priority": {"type": "integer"},
+              "repos": {"type": "array", "items": {"type": "string"}, "description": "Repositories to publish the package to, default ones if empty"},
+              "schedule": {"type": "string", "description": "Cron expression, \"every <interval>\" or \"upstream\", global intervals are used if empty"}
+            }
+          }}}
+        },
+        "responses": {
+          "201": {"description": "Package has been added or already exists"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/packages/{name}": {
+      "parameters": [{"$ref": "#/components/parameters/Name"}],
+      "get": {
+        "summary": "Get package",
+        "responses": {
+          "200": {
+            "description": "Package",
+            "content": {"application/json": {"schema": {
+              "type": "object",
+              "properties": {
+                "package": {"$ref": "#/components/schemas/Package"},
+                "stages": {"type": "array", "items": {"$ref": "#/components/schemas/Stage"}}
+              }
+            }}}
+          },
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "patch": {
+        "summary": "Change options of package, omitted options are not changed and empty values reset them to defaults",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {
+            "type": "object",
+            "properties": {
+              "priority": {"type": "integer"},
+              "clone_url": {"type": "string"},
+              "schedule": {"type": "string"},
+              "timeout": {"type": "integer", "description": "Build timeout in nanoseconds, at least 1m"},
+              "cpu": {"type": "number", "description": "Fraction of CPUs the build container can use"},
+              "memory": {"type": "integer", "description": "Memory limit of the build container in bytes"},
+              "class": {"type": "string", "description": "Resource class limiting concurrent builds"},
+              "env": {"type": "object", "additionalProperties": {"type": "string"}, "description": "Environment variables of the build container, empty values remove variables"},
+              "repos": {"type": "array", "items": {"type": "string"}}
+            }
+          }}}
+        },
+        "responses": {
+          "200": {"description": "Package has been updated"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Remove package from the queue",
+        "responses": {
+          "200": {"description": "Package has been removed"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/packages/{name}/logs": {
+      "parameters": [{"$ref": "#/components/parameters/Name"}],
+      "get": {
+        "summary": "Get log of the last build",
+        "responses": {
+          "200": {"description": "Build log", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/packages/{name}/builds": {
+      "parameters": [
+        {"$ref": "#/components/parameters/Name"},
+        {"name": "limit", "in": "query", "schema": {"type": "integer", "default": 50}}
+      ],
+      "get": {
+        "summary": "List finished builds, newest first",
+        "responses": {
+          "200": {
+            "description": "Builds",
+            "content": {"application/json": {"schema": {
+              "type": "object",
+              "properties": {
+                "builds": {"type": "array", "items": {"$ref": "#/components/schemas/Build"}}
+              }
+            }}}
+          },
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/packages/{name}/rebuild": {
+      "parameters": [{"$ref": "#/components/parameters/Name"}],
+      "post": {
+        "summary": "Queue package for building right away",
+        "responses": {
+          "202": {"description": "Package has been queued"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/packages/{name}/promote": {
+      "parameters": [{"$ref": "#/components/parameters/Name"}],
+      "post": {
+        "summary": "Promote package from testing repositories",
+        "requestBody": {
+          "content": {"application/json": {"schema": {
+            "type": "object",
+            "properties": {
+              "version": {"type": "string", "description": "Version to promote, the staged one if empty"}
+            }
+          }}}
+        },
+        "responses": {
+          "202": {"description": "Promotion has been requested"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/packages/{name}/problems": {
+      "parameters": [{"$ref": "#/components/parameters/Name"}],
+      "post": {
+        "summary": "Report problem with the staged version, it won't be promoted automatically",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {
+            "type": "object",
+            "required": ["problem"],
+            "properties": {"problem": {"type": "string"}}
+          }}}
+        },
+        "responses": {
+          "201": {"description": "Problem has been recorded"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/packages/{name}/pin": {
+      "parameters": [{"$ref": "#/components/parameters/Name"}],
+      "post": {
+        "summary": "Republish archived build of specified version and freeze package",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {
+            "type": "object",
+            "required": ["version"],
+            "properties": {"version": {"type": "string"}}
+          }}}
+        },
+        "responses": {
+          "202": {"description": "Package has been pinned"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/packages/{name}/freeze": {
+      "parameters": [{"$ref": "#/components/parameters/Name"}],
+      "post": {
+        "summary": "Stop rebuilds of package",
+        "responses": {
+          "200": {"description": "Package has been frozen"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/packages/{name}/unfreeze": {
+      "parameters": [{"$ref": "#/components/parameters/Name"}],
+      "post": {
+        "summary": "Resume rebuilds of package and remove its pin",
+        "responses": {
+          "200": {"description": "Package has been unfrozen"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/packages/{name}/rollback": {
+      "parameters": [{"$ref": "#/components/parameters/Name"}],
+      "post": {
+        "summary": "Republish previous successful build and freeze package",
+        "responses": {
+          "202": {
+            "description": "Package has been pinned to the previous build",
+            "content": {"application/json": {"schema": {
+              "type": "object",
+              "properties": {"version": {"type": "string"}}
+            }}}
+          },
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/packages/{name}/pause": {
+      "parameters": [{"$ref": "#/components/parameters/Name"}],
+      "post": {
+        "summary": "Stop scheduled builds of package, running build is finished",
+        "requestBody": {
+          "required": false,
+          "content": {"application/json": {"schema": {
+            "type": "object",
+            "properties": {"reason": {"type": "string"}}
+          }}}
+        },
+        "responses": {
+          "200": {"description": "Package has been paused"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/packages/{name}/resume": {
+      "parameters": [{"$ref": "#/components/parameters/Name"}],
+      "post": {
+        "summary": "Resume scheduled builds of package",
+        "responses": {
+          "200": {"description": "Package has been resumed"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/queue": {
+      "get": {
+        "summary": "List running builds and packages waiting for a build in dispatch order",
+        "responses": {
+          "200": {
+            "description": "Queue with estimated start and finish of builds",
+            "content": {"application/json": {"schema": {
+              "type": "object",
+              "properties": {
+                "queue": {"type": "array", "items": {"$ref": "#/components/schemas/QueueItem"}}
+              }
+            }}}
+          },
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/queue/pause": {
+      "post": {
+        "summary": "Stop dispatching new builds, running builds are finished",
+        "requestBody": {
+          "required": false,
+          "content": {"application/json": {"schema": {
+            "type": "object",
+            "properties": {"reason": {"type": "string"}}
+          }}}
+        },
+        "responses": {
+          "200": {"description": "Queue has been paused"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/queue/resume": {
+      "post": {
+        "summary": "Resume dispatching builds",
+        "responses": {
+          "200": {"description": "Queue has been resumed"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/status": {
+      "get": {
+        "summary": "Show whether the queue is paused and which packages are being built",
+        "responses": {
+          "200": {
+            "description": "Status of the queue",
+            "content": {"application/json": {"schema": {
+              "type": "object",
+              "properties": {
+                "pause": {"$ref": "#/components/schemas/Pause"},
+                "running": {"type": "array", "items": {"type": "string"}},
+                "dispatching": {"type": "array", "items": {"type": "string"}, "description": "Instances which have not stopped for the pause yet"}
+              }
+            }}}
+          },
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearer": {"type": "http", "scheme": "bearer"},
+      "signature": {"type": "apiKey", "in": "header", "name": "X-Aurora-Signature"}
+    },
+    "parameters": {
+      "Name": {"name": "name", "in": "path", "required": true, "schema": {"type": "string"}}
+    },
+    "responses": {
+      "Error": {
+        "description": "Error",
+        "content": {"application/json": {"schema": {
+          "type": "object",
+          "properties": {"error": {"type": "string"}}
+        }}}
+      }
+    },
+    "schemas": {
+      "Package": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string"},
+          "clone_url": {"type": "string"},
+          "version": {"type": "string"},
+          "status": {"type": "string", "enum": ["queued", "processing", "success", "failure", "cancelled", "unknown"]},
+          "instance": {"type": "string"},
+          "date": {"type": "string", "format": "date-time"},
+          "priority": {"type": "integer"},
+          "private": {"type": "boolean"},
+          "owner": {"type": "string"},
+          "repos": {"type": "array", "items": {"type": "string"}},
+          "archive": {"type": "string"},
+          "frozen": {"type": "boolean"},
+          "pin": {
+            "type": "object",
+            "properties": {
+              "version": {"type": "string"},
+              "archive": {"type": "string"},
+              "pending": {"type": "boolean"},
+              "error": {"type": "string"}
+            }
+          },
+          "schedule": {
+            "type": "object",
+            "properties": {
+              "cron": {"type": "string"},
+              "interval": {"type": "integer", "description": "Minimum interval between builds in nanoseconds"},
+              "upstream": {"type": "boolean"}
+            }
+          },
+          "next": {"type": "string", "format": "date-time", "description": "Time of the next planned build, zero if the package is built only on upstream change"},
+          "upstream": {"type": "string"},
+          "failures": {"type": "integer", "description": "Number of consecutive failed builds"},
+          "quarantined": {"type": "boolean", "description": "Package is rebuilt only on upstream change or manual rebuild"},
+          "failure": {"$ref": "#/components/schemas/Failure"},
+          "timeout": {"type": "integer", "description": "Build timeout in nanoseconds, global one if 0"},
+          "resources": {
+            "type": "object",
+            "properties": {
+              "cpu": {"type": "number"},
+              "memory": {"type": "integer"}
+            }
+          },
+          "env": {"type": "object", "additionalProperties": {"type": "string"}},
+          "class": {"type": "string"},
+          "paused": {"$ref": "#/components/schemas/Pause"}
+        }
+      },
+      "Pause": {
+        "type": "object",
+        "properties": {
+          "by": {"type": "string"},
+          "since": {"type": "string", "format": "date-time"},
+          "reason": {"type": "string"}
+        }
+      },
+      "Build": {
+        "type": "object",
+        "properties": {
+          "package": {"type": "string"},
+          "instance": {"type": "string"},
+          "status": {"type": "string"},
+          "version": {"type": "string"},
+          "started": {"type": "string", "format": "date-time"},
+          "finished": {"type": "string", "format": "date-time"},
+          "duration": {"type": "integer", "description": "nanoseconds"},
+          "archive": {"type": "string"},
+          "failure": {"$ref": "#/components/schemas/Failure"}
+        }
+      },
+      "Failure": {
+        "type": "object",
+        "description": "Why the build has failed, recognised by its log",
+        "properties": {
+          "category": {"type": "string", "description": "One of missing_dependency, checksum, download, compile, disk_full, timeout, unknown or a category of classify.rules"},
+          "excerpt": {"type": "string"}
+        }
+      },
+      "QueueItem": {
+        "type": "object",
+        "properties": {
+          "package": {"type": "string"},
+          "status": {"type": "string"},
+          "priority": {"type": "integer"},
+          "effective": {"type": "integer", "description": "Priority raised by waiting time, packages are dispatched by it"},
+          "class": {"type": "string"},
+          "instance": {"type": "string", "description": "Estimated unless the build is running, see start"},
+          "waiting": {"type": "integer", "description": "How long the package has been due for a build in nanoseconds"},
+          "duration": {"type": "integer", "description": "Average duration of recent builds in nanoseconds, 0 if unknown"},
+          "start": {"type": "string", "format": "date-time", "description": "Estimated unless the build is running, waiting builds are spread across instances running builds now assuming they have threads and classes of the instance serving the request"},
+          "finish": {"type": "string", "format": "date-time"}
+        }
+      },
+      "Stage": {
+        "type": "object",
+        "properties": {
+          "package": {"type": "string"},
+          "repo": {"type": "string"},
+          "target": {"type": "string"},
+          "version": {"type": "string"},
+          "published": {"type": "string", "format": "date-time"},
+          "problems": {"type": "array", "items": {"type": "string"}},
+          "requested": {"type": "boolean"},
+          "requested_version": {"type": "string"},
+          "promoted": {"type": "string"},
+          "promoted_at": {"type": "string", "format": "date-time"},
+          "failed": {"type": "string"},
+          "error": {"type": "string"}
+        }
+      }
+    }
+  }
+}
+`
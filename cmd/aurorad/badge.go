@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/kovetskiy/aurora/pkg/proto"
+)
+
+const (
+	badgeColorGreen  = "#4c1"
+	badgeColorRed    = "#e05d44"
+	badgeColorBlue   = "#007ec6"
+	badgeColorYellow = "#dfb317"
+	badgeColorGrey   = "#9f9f9f"
+
+	// badgeCharWidth is approximate width of a character of 11px Verdana
+	badgeCharWidth = 7
+	badgePadding   = 10
+)
+
+var badgeTemplate = template.Must(template.New("badge").Parse(
+	`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20">` +
+		`<title>{{.Label}}: {{.Message}}</title>` +
+		`<rect width="{{.LabelWidth}}" height="20" fill="#555"/>` +
+		`<rect x="{{.LabelWidth}}" width="{{.MessageWidth}}" height="20" fill="{{.Color}}"/>` +
+		`<g fill="#fff" text-anchor="middle" ` +
+		`font-family="Verdana,DejaVu Sans,sans-serif" font-size="11">` +
+		`<text x="{{.LabelX}}" y="14">{{.Label}}</text>` +
+		`<text x="{{.MessageX}}" y="14">{{.Message}}</text>` +
+		`</g></svg>`,
+))
+
+type badge struct {
+	Label   string
+	Message string
+	Color   string
+}
+
+func (badge badge) LabelWidth() int {
+	return len(badge.Label)*badgeCharWidth + badgePadding
+}
+
+func (badge badge) MessageWidth() int {
+	return len(badge.Message)*badgeCharWidth + badgePadding
+}
+
+func (badge badge) Width() int {
+	return badge.LabelWidth() + badge.MessageWidth()
+}
+
+func (badge badge) LabelX() float64 {
+	return float64(badge.LabelWidth()) / 2
+}
+
+func (badge badge) MessageX() float64 {
+	return float64(badge.LabelWidth()) + float64(badge.MessageWidth())/2
+}
+
+func (ui *UI) serveStatusBadge(response http.ResponseWriter, request *http.Request) {
+	ui.serveBadge(response, request, "aurora", func(pkg *proto.Package) badge {
+		color := badgeColorGrey
+		switch pkg.Status {
+		case proto.BuildStatusSuccess.String():
+			color = badgeColorGreen
+		case proto.BuildStatusFailure.String():
+			color = badgeColorRed
+		case proto.BuildStatusProcessing.String():
+			color = badgeColorBlue
+		case proto.BuildStatusQueued.String():
+			color = badgeColorYellow
+		}
+
+		return badge{Message: pkg.Status, Color: color}
+	})
+}
+
+func (ui *UI) serveVersionBadge(response http.ResponseWriter, request *http.Request) {
+	ui.serveBadge(response, request, "aurora", func(pkg *proto.Package) badge {
+		if pkg.Version == "" {
+			return badge{Message: "not built", Color: badgeColorGrey}
+		}
+
+		return badge{Message: pkg.Version, Color: badgeColorBlue}
+	})
+}
+
+// serveBadge renders badge of the package specified in URL, errors are
+// rendered as badges too, so they are visible where the badge is embedded.
+func (ui *UI) serveBadge(
+	response http.ResponseWriter,
+	request *http.Request,
+	label string,
+	describe func(*proto.Package) badge,
+) {
+	status := http.StatusOK
+
+	var result badge
+
+	signer, err := ui.authorize(request, "PackageService.GetPackage")
+	if err == nil {
+		var pkg *proto.Package
+
+		pkg, err = ui.findPackage(signer, chi.URLParam(request, "name"))
+		if err == nil {
+			result = describe(pkg)
+		}
+	}
+
+	if err != nil {
+		status = http.StatusNotFound
+		result = badge{Message: "not found", Color: badgeColorGrey}
+	}
+
+	result.Label = label
+
+	response.Header().Set("Content-Type", "image/svg+xml")
+	response.Header().Set("Cache-Control", "no-cache, max-age=0")
+	response.WriteHeader(status)
+
+	err = badgeTemplate.Execute(response, result)
+	if err != nil {
+		errorh(err, "unable to render badge")
+	}
+}
+
+// getBadgeMarkdown returns markdown for embedding badge of the package.
+func getBadgeMarkdown(baseURL string, name string, kind string) string {
+	return fmt.Sprintf(
+		"![%s](%s%s/packages/%s/%s.svg)",
+		kind, baseURL, uiPrefix, name, kind,
+	)
+}
@@ -7,37 +7,26 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
+	"github.com/kovetskiy/aurora/pkg/archive"
+	"github.com/kovetskiy/aurora/pkg/bus"
+	"github.com/kovetskiy/aurora/pkg/classify"
+	"github.com/kovetskiy/aurora/pkg/history"
+	"github.com/kovetskiy/aurora/pkg/pause"
 	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/schedule"
+	"github.com/kovetskiy/aurora/pkg/staging"
+	"github.com/kovetskiy/aurora/pkg/vercmp"
 	"github.com/kovetskiy/lorg"
 	"github.com/reconquest/faces/execution"
 	"github.com/reconquest/karma-go"
 	"github.com/reconquest/lexec-go"
-	"github.com/reconquest/regexputil-go"
-)
-
-const (
-	reArchiveTime = `(?P<time>\d+)`
-	reArchiveName = `(?P<name>[a-z0-9][a-z0-9@\._+-]+)`
-	reArchiveVer  = `(?P<ver>[a-z0-9_.]+-[0-9]+)`
-	reArchiveArch = `(?P<arch>(i686|x86_64))`
-	reArchiveExt  = `(?P<ext>tar(.(gz|bz2|xz|zst|lrz|lzo|sz))?)`
-
-	packagesDatabaseFile = "aurora.db.tar"
-)
-
-var reArchiveFilename = regexp.MustCompile(
-	`^` + reArchiveTime +
-		`\.` + reArchiveName +
-		`-` + reArchiveVer +
-		`-` + reArchiveArch +
-		`\.pkg\.` + reArchiveExt + `$`,
 )
 
 const (
@@ -49,11 +38,15 @@ type build struct {
 	storage *mgo.Collection
 	pkg     proto.Package
 
-	instance      string
-	repoDir       string
-	bufferDir     string
-	logsDir       string
-	configHistory ConfigHistory
+	// ctx is cancelled when the daemon is shutting down and can't wait for
+	// the build anymore
+	ctx      context.Context
+	registry *buildRegistry
+
+	instance  string
+	repos     []*Repository
+	bufferDir string
+	logsDir   string
 
 	cloud *Cloud
 
@@ -63,6 +56,19 @@ type build struct {
 	ID        string
 	process   *execution.Operation
 	bus       *Bus
+	notifier  proto.Notifier
+	builds    *history.History
+	staging   *staging.Staging
+	pauses    *pause.Queue
+	schedule  schedule.Defaults
+	timeout   time.Duration
+	timedOut  bool
+
+	classifier *classify.Classifier
+
+	// quarantine is the number of consecutive failures after which the
+	// package is quarantined, 0 disables quarantine
+	quarantine int
 }
 
 var dbLock = &sync.Mutex{}
@@ -74,13 +80,36 @@ func (build *build) String() string {
 func (build *build) updateStatus(status proto.BuildStatus) {
 	build.pkg.Status = status.String()
 	build.pkg.Instance = build.instance
+	build.refreshSchedule()
+
+	quarantined := build.countFailures(status)
+
+	build.pkg.Next = schedule.Next(build.pkg, build.schedule)
 
 	build.bus.Publish(build.pkg.Name, status)
+	build.publishEvent(proto.NewEvent(proto.EventStatus, build.pkg))
+
+	// options of the package can be changed while it's being built, so
+	// only fields owned by the build are updated
+	set := bson.M{
+		"status":      build.pkg.Status,
+		"instance":    build.pkg.Instance,
+		"date":        build.pkg.Date,
+		"next":        build.pkg.Next,
+		"version":     build.pkg.Version,
+		"archive":     build.pkg.Archive,
+		"failures":    build.pkg.Failures,
+		"quarantined": build.pkg.Quarantined,
+	}
 
-	err := build.storage.Update(
-		bson.M{"name": build.pkg.Name},
-		build.pkg,
-	)
+	update := bson.M{"$set": set}
+	if build.pkg.Failure != nil {
+		set["failure"] = build.pkg.Failure
+	} else {
+		update["$unset"] = bson.M{"failure": ""}
+	}
+
+	err := build.storage.Update(bson.M{"name": build.pkg.Name}, update)
 	if err != nil {
 		build.log.Error(
 			karma.Format(
@@ -91,6 +120,66 @@ func (build *build) updateStatus(status proto.BuildStatus) {
 	}
 
 	build.log.Infof("status: %s", status)
+
+	if quarantined {
+		build.log.Warningf(
+			"package has been quarantined after %d consecutive failures",
+			build.pkg.Failures,
+		)
+
+		build.publishEvent(
+			proto.NewEvent(proto.EventPackageQuarantined, build.pkg),
+		)
+	}
+}
+
+// countFailures updates the counter of consecutive failures according to
+// the finished build and reports whether the package has just been
+// quarantined.
+func (build *build) countFailures(status proto.BuildStatus) bool {
+	switch status {
+	case proto.BuildStatusSuccess:
+		build.pkg.Failures = 0
+		build.pkg.Quarantined = false
+
+	case proto.BuildStatusFailure:
+		build.pkg.Failures++
+
+		if build.quarantine > 0 &&
+			build.pkg.Failures >= build.quarantine &&
+			!build.pkg.Quarantined {
+			build.pkg.Quarantined = true
+			return true
+		}
+	}
+
+	return false
+}
+
+// refreshSchedule loads schedule of the package which could be changed
+// while the package is being built.
+func (build *build) refreshSchedule() {
+	var current proto.Package
+
+	err := build.storage.
+		Find(bson.M{"name": build.pkg.Name}).
+		Select(bson.M{"schedule": 1}).
+		One(&current)
+	if err != nil {
+		build.log.Error(
+			karma.Format(
+				err, "can't load schedule of package",
+			),
+		)
+		return
+	}
+
+	build.pkg.Schedule = current.Schedule
+}
+
+func (build *build) publishEvent(event proto.Event) {
+	build.bus.Publish(bus.TopicEvents, event)
+	build.notifier.Notify(event)
 }
 
 func (build *build) init() bool {
@@ -101,62 +190,301 @@ func (build *build) init() bool {
 	return true
 }
 
+// isPaused returns true if the queue is paused, the build is not skipped if
+// it can't be checked.
+func (build *build) isPaused() bool {
+	current, err := build.pauses.Get()
+	if err != nil {
+		build.log.Error(err)
+		return false
+	}
+
+	return current != nil
+}
+
 func (build *build) Process() {
+	// slot of the class is reserved by the processor before dispatching
+	defer build.registry.release(build.pkg.Class)
+
 	if !build.init() {
 		return
 	}
 
-	build.cleanup()
+	// the build could wait for a free thread since before the queue was
+	// paused
+	if build.isPaused() {
+		build.log.Infof("skipping build: queue is paused")
+		return
+	}
+
+	if !build.registry.start(build.pkg.Name) {
+		build.log.Infof("skipping build: shutting down")
+		return
+	}
+
+	defer build.registry.finish(build.pkg.Name)
+
+	metricThreadsBusy.Inc()
+	defer metricThreadsBusy.Dec()
+
+	for _, repo := range build.repos {
+		err := build.cleanup(repo)
+		if err != nil {
+			build.log.Error(err)
+		}
+	}
+
+	before := build.pkg.Status
+
+	// new subscribers should receive log of the current build only
+	build.bus.Reset(build.pkg.Name)
 
 	build.pkg.Date = time.Now()
+	build.pkg.Failure = nil
 	build.updateStatus(proto.BuildStatusProcessing)
 
+	build.publishEvent(proto.NewEvent(proto.EventBuildStarted, build.pkg))
+
+	defer func() {
+		event := proto.NewEvent(proto.EventBuildFinished, build.pkg)
+		event.Duration = time.Since(build.pkg.Date)
+		event.PreviousStatus = before
+
+		build.publishEvent(event)
+
+		metricBuildDuration.WithLabelValues(build.pkg.Status).
+			Observe(event.Duration.Seconds())
+		metricBuilds.WithLabelValues(build.pkg.Name, build.pkg.Status).Inc()
+
+		record := proto.Build{
+			Package:  build.pkg.Name,
+			Instance: build.instance,
+			Status:   build.pkg.Status,
+			Version:  build.pkg.Version,
+			Started:  build.pkg.Date,
+			Finished: build.pkg.Date.Add(event.Duration),
+			Duration: event.Duration,
+		}
+
+		switch build.pkg.Status {
+		case proto.BuildStatusSuccess.String():
+			record.Archive = build.pkg.Archive
+		case proto.BuildStatusFailure.String():
+			record.Failure = build.pkg.Failure
+		}
+
+		err := build.builds.Record(record)
+		if err != nil {
+			build.log.Error(err)
+		}
+	}()
+
 	archive, err := build.build()
 	if err != nil {
+		if build.ctx.Err() != nil {
+			build.log.Warningf("build has been cancelled: %s", err)
+
+			build.updateStatus(proto.BuildStatusCancelled)
+			return
+		}
+
 		build.log.Error(err)
 
+		build.pkg.Failure = build.classifyBuild(err)
 		build.updateStatus(proto.BuildStatusFailure)
 		return
 	}
 
 	build.log.Infof("package is ready in buffer: %s", archive)
 
-	repoPath := filepath.Join(build.repoDir, filepath.Base(archive))
-
-	err = os.Rename(archive, repoPath)
+	err = build.publish(archive)
 	if err != nil {
-		build.log.Error(
-			karma.Format(
-				err,
-				"unable to move file from buffer",
-			),
-		)
+		build.log.Error(err)
 
+		build.pkg.Failure = build.classifyError(err)
 		build.updateStatus(proto.BuildStatusFailure)
 		return
 	}
 
-	build.log.Infof("adding archive %s to aurora repository", repoPath)
+	build.updateVersion(archive)
+
+	build.pkg.Archive = filepath.Base(archive)
+
+	build.stage()
+
+	build.updateStatus(proto.BuildStatusSuccess)
+}
+
+// classifyBuild recognises why the build has failed by its log, the error
+// is classified instead if the log can't be read, e.g. the container
+// hasn't been started.
+func (build *build) classifyBuild(err error) *proto.Failure {
+	if build.timedOut {
+		return &proto.Failure{
+			Category: classify.CategoryTimeout,
+			Excerpt:  fmt.Sprintf("build timed out after %s", build.timeout),
+		}
+	}
+
+	logs, logErr := os.Open(filepath.Join(build.logsDir, build.pkg.Name))
+	if logErr != nil {
+		build.log.Warning(
+			karma.Format(logErr, "can't open logs to classify failure"),
+		)
+
+		return build.classifyError(err)
+	}
+
+	defer logs.Close()
+
+	failure, logErr := build.classifier.Classify(logs)
+	if logErr != nil {
+		build.log.Warning(karma.Format(logErr, "can't classify failure"))
 
-	err = build.repoAdd(repoPath)
+		return build.classifyError(err)
+	}
+
+	return failure
+}
+
+func (build *build) classifyError(err error) *proto.Failure {
+	failure, classifyErr := build.classifier.Classify(
+		strings.NewReader(err.Error()),
+	)
+	if classifyErr != nil {
+		return &proto.Failure{
+			Category: classify.CategoryUnknown,
+			Excerpt:  err.Error(),
+		}
+	}
+
+	return failure
+}
+
+// stage records the published version for promotion from testing
+// repositories of the package.
+func (build *build) stage() {
+	for _, repo := range build.repos {
+		if repo.PromoteTo == "" {
+			continue
+		}
+
+		err := build.staging.Stage(proto.Stage{
+			Package:   build.pkg.Name,
+			Repo:      repo.Name,
+			Target:    repo.PromoteTo,
+			Version:   build.pkg.Version,
+			Published: time.Now(),
+		})
+		if err != nil {
+			build.log.Error(err)
+		}
+	}
+}
+
+// publish adds the archive to every repository of the package and removes
+// it from the buffer.
+func (build *build) publish(archive string) error {
+	defer func() {
+		err := os.Remove(archive)
+		if err != nil {
+			build.log.Error(
+				karma.Format(err, "unable to remove archive from buffer"),
+			)
+		}
+	}()
+
+	if len(build.repos) == 0 {
+		return errors.New("package is not assigned to any repository")
+	}
+
+	for _, repo := range build.repos {
+		err := build.addToRepository(repo, archive)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addToRepository copies the archive to the repository directory, signs it
+// if the repository has a key and adds it to the database.
+func (build *build) addToRepository(repo *Repository, archive string) error {
+	path := filepath.Join(repo.Dir, filepath.Base(archive))
+
+	err := linkFile(archive, path)
 	if err != nil {
-		build.log.Error(
-			karma.Format(
-				err, "can't update aurora repository",
-			),
+		return karma.Format(
+			err,
+			"unable to copy %s to %s repository", archive, repo,
 		)
-		build.updateStatus(proto.BuildStatusFailure)
+	}
+
+	if repo.Key != "" {
+		err = build.sign(repo, path)
+		if err != nil {
+			return karma.Format(
+				err, "can't sign archive for %s repository", repo,
+			)
+		}
+	}
 
+	build.log.Infof("adding archive %s to %s repository", path, repo)
+
+	err = build.repoAdd(repo, path)
+	if err != nil {
+		return karma.Format(
+			err, "can't update %s repository", repo,
+		)
+	}
+
+	return nil
+}
+
+// sign creates detached signature of the archive with key of the repository.
+func (build *build) sign(repo *Repository, path string) error {
+	cmd := exec.Command(
+		"gpg", "--batch", "--yes", "--detach-sign",
+		"--local-user", repo.Key,
+		"--output", path+".sig",
+		path,
+	)
+
+	return lexec.NewExec(lexec.Loggerf(build.log.Tracef), cmd).Run()
+}
+
+// updateVersion sets version of the package to the version of published
+// archive and notifies if it has been changed.
+func (build *build) updateVersion(path string) {
+	parsed, ok := archive.Parse(filepath.Base(path))
+	if !ok {
+		build.log.Warningf(
+			"unable to get version of archive: %s", filepath.Base(path),
+		)
 		return
 	}
 
-	build.updateStatus(proto.BuildStatusSuccess)
+	version := parsed.Version
+	if vercmp.Compare(version, build.pkg.Version) == 0 {
+		return
+	}
+
+	event := proto.NewEvent(proto.EventVersionChanged, build.pkg)
+	event.Version = version
+	event.PreviousVersion = build.pkg.Version
+
+	build.pkg.Version = version
+
+	build.log.Infof("version: %s -> %s", event.PreviousVersion, version)
+
+	build.publishEvent(event)
 }
 
-func (build *build) cleanup() error {
+func (build *build) cleanup(repo *Repository) error {
 	globbed, err := filepath.Glob(
 		filepath.Join(
-			fmt.Sprintf("%s/*.%s-*-*-*.pkg.*", build.repoDir, build.pkg.Name),
+			fmt.Sprintf("%s/*.%s-*-*-*.pkg.*", repo.Dir, build.pkg.Name),
 		),
 	)
 	if err != nil {
@@ -166,27 +494,27 @@ func (build *build) cleanup() error {
 		)
 	}
 
-	type archive struct {
+	type file struct {
 		Time     string
 		Basename string
 	}
 
-	builds := map[string][]archive{}
+	builds := map[string][]file{}
 	for _, fullpath := range globbed {
 		basename := filepath.Base(fullpath)
 
-		matches := reArchiveFilename.FindStringSubmatch(basename)
-
-		name := regexputil.Subexp(reArchiveFilename, matches, "name")
-		if name != build.pkg.Name {
+		parsed, ok := archive.Parse(basename)
+		if !ok {
+			// signatures are removed along with archives
 			continue
 		}
 
-		ver := regexputil.Subexp(reArchiveFilename, matches, "ver")
-		time := regexputil.Subexp(reArchiveFilename, matches, "time")
+		if parsed.Name != build.pkg.Name {
+			continue
+		}
 
-		builds[ver] = append(builds[ver], archive{
-			Time:     time,
+		builds[parsed.Version] = append(builds[parsed.Version], file{
+			Time:     parsed.Time,
 			Basename: basename,
 		})
 	}
@@ -197,40 +525,55 @@ func (build *build) cleanup() error {
 	}
 
 	trash := []string{}
-	if len(versions) > build.configHistory.Versions {
-		max := build.configHistory.Versions
+	if len(versions) > repo.History.Versions {
+		max := repo.History.Versions
 
-		sort.Sort(sort.StringSlice(versions))
+		// newest versions first
+		sort.Slice(versions, func(i, j int) bool {
+			return vercmp.Compare(versions[i], versions[j]) > 0
+		})
 
 		for _, version := range versions[max:] {
-			for _, archive := range builds[version] {
-				trash = append(trash, archive.Basename)
+			for _, file := range builds[version] {
+				trash = append(trash, file.Basename)
 			}
 
 			delete(builds, version)
 		}
 	}
 
-	for _, archives := range builds {
-		if len(archives) <= build.configHistory.BuildsPerVersion {
+	for _, files := range builds {
+		if len(files) <= repo.History.BuildsPerVersion {
 			continue
 		}
 
-		sort.Slice(archives, func(i, j int) bool {
-			return archives[i].Time < archives[j].Time
+		// newest builds first
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].Time > files[j].Time
 		})
 
-		for _, archive := range archives[build.configHistory.BuildsPerVersion:] {
-			trash = append(trash, archive.Basename)
+		for _, file := range files[repo.History.BuildsPerVersion:] {
+			trash = append(trash, file.Basename)
 		}
 	}
 
-	for _, archive := range trash {
-		fullpath := filepath.Join(build.repoDir, archive)
+	for _, basename := range trash {
+		fullpath := filepath.Join(repo.Dir, basename)
 
 		build.log.Tracef("removing old pkg: %s", fullpath)
 
-		err := os.Remove(fullpath)
+		err := os.Remove(fullpath + ".sig")
+		if err != nil && !os.IsNotExist(err) {
+			build.log.Error(
+				karma.Format(
+					err,
+					"unable to remove signature of old pkg: %s",
+					fullpath,
+				),
+			)
+		}
+
+		err = os.Remove(fullpath)
 		if err != nil {
 			build.log.Error(
 				karma.Format(
@@ -245,15 +588,11 @@ func (build *build) cleanup() error {
 	return nil
 }
 
-func (build *build) repoAdd(path string) error {
+func (build *build) repoAdd(repo *Repository, path string) error {
 	dbLock.Lock()
 	defer dbLock.Unlock()
 
-	cmd := exec.Command(
-		"repo-add",
-		filepath.Join(build.repoDir, packagesDatabaseFile),
-		path,
-	)
+	cmd := exec.Command("repo-add", withSigning(repo, repo.Database(), path)...)
 
 	err := lexec.NewExec(lexec.Loggerf(build.log.Tracef), cmd).Run()
 	if err != nil {
@@ -263,15 +602,11 @@ func (build *build) repoAdd(path string) error {
 	return nil
 }
 
-func (build *build) repoRemove(path string) error {
+func (build *build) repoRemove(repo *Repository, name string) error {
 	dbLock.Lock()
 	defer dbLock.Unlock()
 
-	cmd := exec.Command(
-		"repo-remove",
-		filepath.Join(build.repoDir, packagesDatabaseFile),
-		path,
-	)
+	cmd := exec.Command("repo-remove", withSigning(repo, repo.Database(), name)...)
 
 	err := lexec.NewExec(lexec.Loggerf(build.log.Tracef), cmd).Run()
 	if err != nil {
@@ -281,6 +616,16 @@ func (build *build) repoRemove(path string) error {
 	return nil
 }
 
+// withSigning prepends arguments of repo-add and repo-remove with flags
+// for signing the database if the repository has a key.
+func withSigning(repo *Repository, args ...string) []string {
+	if repo.Key == "" {
+		return args
+	}
+
+	return append([]string{"--sign", "--key", repo.Key}, args...)
+}
+
 func (build *build) build() (string, error) {
 	defer build.shutdown()
 
@@ -357,8 +702,7 @@ func (build *build) runContainer() (string, error) {
 	container, err := build.cloud.CreateContainer(
 		build.bufferDir,
 		build.container,
-		build.pkg.Name,
-		build.pkg.CloneURL,
+		build.pkg,
 	)
 	if err != nil {
 		return "", karma.Format(
@@ -392,8 +736,9 @@ func (build *build) runContainer() (string, error) {
 		})
 	}()
 
-	timeout, err := build.cloud.WaitContainer(container)
+	timeout, err := build.cloud.WaitContainer(build.ctx, container, build.timeout)
 	if timeout {
+		build.timedOut = true
 		err = errors.New("build timed out")
 	}
 
@@ -1,51 +1,196 @@
 package main
 
 import (
+	"fmt"
 	"sync"
+	"sync/atomic"
 )
 
-type BusSubscription chan interface{}
+const (
+	// BusPolicyDrop drops messages which don't fit into subscriber's queue.
+	BusPolicyDrop = "drop"
+
+	// BusPolicyDisconnect closes subscription which can't keep up with
+	// publisher.
+	BusPolicyDisconnect = "disconnect"
+
+	defaultBusQueue   = 1024
+	defaultBusHistory = 10000
+)
+
+type BusSubscription struct {
+	queue   chan interface{}
+	replay  []interface{}
+	dropped int64
+}
+
+// Messages returns channel with messages published after subscribing, the
+// channel is closed when subscription is terminated.
+func (sub *BusSubscription) Messages() <-chan interface{} {
+	return sub.queue
+}
+
+// Replay returns messages that were published to the topic before
+// subscribing, oldest first.
+func (sub *BusSubscription) Replay() []interface{} {
+	return sub.replay
+}
+
+// TakeDropped returns amount of messages dropped since the last call.
+func (sub *BusSubscription) TakeDropped() int64 {
+	return atomic.SwapInt64(&sub.dropped, 0)
+}
+
+// busHistory is a ring buffer of last published messages.
+type busHistory struct {
+	items []interface{}
+	start int
+	size  int
+}
+
+func (history *busHistory) push(data interface{}) {
+	if len(history.items) == 0 {
+		return
+	}
+
+	index := (history.start + history.size) % len(history.items)
+	history.items[index] = data
+
+	if history.size < len(history.items) {
+		history.size++
+	} else {
+		history.start = (history.start + 1) % len(history.items)
+	}
+}
+
+func (history *busHistory) list() []interface{} {
+	result := make([]interface{}, history.size)
+	for i := 0; i < history.size; i++ {
+		result[i] = history.items[(history.start+i)%len(history.items)]
+	}
+
+	return result
+}
 
 type Bus struct {
-	mutex  *sync.Mutex
-	topics map[string]struct{}
-	subs   map[string][]BusSubscription
+	mutex   *sync.Mutex
+	topics  map[string]*busHistory
+	subs    map[string][]*BusSubscription
+	queue   int
+	history int
+	policy  string
 }
 
-func NewBus() *Bus {
-	return &Bus{
-		mutex:  &sync.Mutex{},
-		topics: map[string]struct{}{},
-		subs:   map[string][]BusSubscription{},
+func NewBus(config ConfigBus) (*Bus, error) {
+	bus := &Bus{
+		mutex:   &sync.Mutex{},
+		topics:  map[string]*busHistory{},
+		subs:    map[string][]*BusSubscription{},
+		queue:   config.Queue,
+		history: config.History,
+		policy:  config.Policy,
+	}
+
+	if bus.queue <= 0 {
+		bus.queue = defaultBusQueue
 	}
+
+	if bus.history < 0 {
+		bus.history = 0
+	} else if bus.history == 0 {
+		bus.history = defaultBusHistory
+	}
+
+	switch bus.policy {
+	case "":
+		bus.policy = BusPolicyDrop
+	case BusPolicyDrop, BusPolicyDisconnect:
+	default:
+		return nil, fmt.Errorf(
+			"unexpected bus policy %q, expected %q or %q",
+			bus.policy, BusPolicyDrop, BusPolicyDisconnect,
+		)
+	}
+
+	return bus, nil
 }
 
-// Subscribe returns channel subscribed on events.
-func (bus *Bus) Subscribe(topic string) (BusSubscription, bool) {
+// Subscribe returns subscription on events of the topic and flag indicating
+// that the topic has been already published to.
+func (bus *Bus) Subscribe(topic string) (*BusSubscription, bool) {
 	bus.mutex.Lock()
 	defer bus.mutex.Unlock()
 
-	sub := make(chan interface{}, 1)
+	sub := &BusSubscription{
+		queue: make(chan interface{}, bus.queue),
+	}
 
 	bus.subs[topic] = append(bus.subs[topic], sub)
 
-	_, ok := bus.topics[topic]
+	history, ok := bus.topics[topic]
+	if ok {
+		sub.replay = history.list()
+	}
 
 	return sub, ok
 }
 
+// Publish sends message to every subscriber of the topic without blocking,
+// subscribers with full queue are handled according to bus policy.
 func (bus *Bus) Publish(topic string, data interface{}) {
 	bus.mutex.Lock()
 	defer bus.mutex.Unlock()
-	if _, ok := bus.topics[topic]; !ok {
-		bus.topics[topic] = struct{}{}
+
+	history, ok := bus.topics[topic]
+	if !ok {
+		history = &busHistory{items: make([]interface{}, bus.history)}
+		bus.topics[topic] = history
 	}
 
+	history.push(data)
+
+	slow := []*BusSubscription{}
 	for _, sub := range bus.subs[topic] {
-		sub <- data
+		select {
+		case sub.queue <- data:
+		default:
+			if bus.policy == BusPolicyDisconnect {
+				slow = append(slow, sub)
+			} else {
+				atomic.AddInt64(&sub.dropped, 1)
+			}
+		}
+	}
+
+	for _, sub := range slow {
+		warningf("bus: disconnecting slow subscriber of %s", topic)
+
+		bus.unsubscribe(topic, sub)
 	}
 }
 
+// Reset forgets history of the topic, so new subscribers will not receive
+// messages published before.
+func (bus *Bus) Reset(topic string) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	delete(bus.topics, topic)
+}
+
+// CountSubscribers returns amount of subscribers across all topics.
+func (bus *Bus) CountSubscribers() int {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	count := 0
+	for _, subs := range bus.subs {
+		count += len(subs)
+	}
+
+	return count
+}
+
 func (bus *Bus) Close(topic string) {
 	bus.mutex.Lock()
 	defer bus.mutex.Unlock()
@@ -57,21 +202,22 @@ func (bus *Bus) Close(topic string) {
 	delete(bus.topics, topic)
 }
 
-func (bus *Bus) Unsubscribe(topic string, sub BusSubscription) {
+func (bus *Bus) Unsubscribe(topic string, sub *BusSubscription) {
 	bus.mutex.Lock()
 	defer bus.mutex.Unlock()
 
 	bus.unsubscribe(topic, sub)
 }
 
-func (bus *Bus) unsubscribe(topic string, sub BusSubscription) {
-	found := true
+func (bus *Bus) unsubscribe(topic string, sub *BusSubscription) {
+	found := false
 	for i := 0; i < len(bus.subs[topic]); i++ {
 		if bus.subs[topic][i] == sub {
 			bus.subs[topic] = append(
 				bus.subs[topic][:i],
 				bus.subs[topic][i+1:]...,
 			)
+			found = true
 			break
 		}
 	}
@@ -79,5 +225,5 @@ func (bus *Bus) unsubscribe(topic string, sub BusSubscription) {
 		return
 	}
 
-	close(sub)
+	close(sub.queue)
 }
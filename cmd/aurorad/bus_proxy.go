@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/gorilla/websocket"
+	"github.com/kovetskiy/aurora/pkg/bus"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/rpc"
+)
+
+// BusProxy relays bus stream from the processor which builds the package,
+// so clients need to reach only the web server. Caller must pass either a
+// token issued by PackageService.GetBus or a signature header.
+//
+// Firehose stream (?all=1) is merged from bus servers of all instances.
+type BusProxy struct {
+	collection *mgo.Collection
+	auth       *rpc.AuthService
+	tokens     *rpc.Tokens
+	instance   string
+}
+
+func NewBusProxy(
+	collection *mgo.Collection,
+	auth *rpc.AuthService,
+	tokens *rpc.Tokens,
+	instance string,
+) *BusProxy {
+	return &BusProxy{
+		collection: collection,
+		auth:       auth,
+		tokens:     tokens,
+		instance:   instance,
+	}
+}
+
+func (proxy *BusProxy) ServeHTTP(
+	response http.ResponseWriter,
+	request *http.Request,
+) {
+	query := request.URL.Query()
+
+	topic := query.Get("package")
+	if query.Get("all") != "" {
+		topic = bus.TopicEvents
+	}
+
+	if topic == "" {
+		response.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	public, err := proxy.authorize(request, topic)
+	if err != nil {
+		http.Error(response, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var (
+		instances []string
+		params    = url.Values{}
+	)
+
+	if topic == bus.TopicEvents {
+		instances, err = proxy.getInstances()
+		if err != nil {
+			errorh(err, "unable to get list of instances")
+
+			http.Error(response, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		params.Set("all", "1")
+		for _, key := range []string{"status", "glob", "owner"} {
+			if values, ok := query[key]; ok {
+				params[key] = values
+			}
+		}
+
+		if public {
+			params.Set("public", "1")
+		}
+	} else {
+		pkg, err := proxy.getPackage(topic, public)
+		if err != nil {
+			http.Error(response, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		instance := pkg.Instance
+		if instance == "" {
+			instance = proxy.instance
+		}
+
+		instances = []string{instance}
+		params.Set("package", pkg.Name)
+	}
+
+	upstreams := []*websocket.Conn{}
+	defer func() {
+		for _, upstream := range upstreams {
+			upstream.Close()
+		}
+	}()
+
+	for _, instance := range instances {
+		address := fmt.Sprintf(
+			"ws://%s:%d/?%s",
+			instance,
+			proto.DefaultBusServerPort,
+			params.Encode(),
+		)
+
+		upstream, _, err := websocket.DefaultDialer.Dial(address, nil)
+		if err != nil {
+			errorh(err, "unable to connect to bus at %s", address)
+
+			// firehose should work even if some instances are down
+			if topic == bus.TopicEvents {
+				continue
+			}
+
+			http.Error(
+				response,
+				"unable to connect to bus of "+instance,
+				http.StatusBadGateway,
+			)
+			return
+		}
+
+		upstreams = append(upstreams, upstream)
+	}
+
+	upgrader := &websocket.Upgrader{}
+
+	connection, err := upgrader.Upgrade(response, request, nil)
+	if err != nil {
+		errorh(err, "unable to upgrade connection")
+		return
+	}
+
+	defer connection.Close()
+
+	relayBus(connection, upstreams)
+}
+
+// authorize checks token or signature of the request and returns true if
+// the caller is anonymous and should not see private packages.
+func (proxy *BusProxy) authorize(
+	request *http.Request,
+	topic string,
+) (bool, error) {
+	token := request.URL.Query().Get("token")
+	if token != "" {
+		subject, err := proxy.tokens.Verify(token)
+		if err != nil {
+			return false, err
+		}
+
+		granted, public, err := rpc.ParseBusTokenSubject(subject)
+		if err != nil {
+			return false, err
+		}
+
+		if granted != topic {
+			return false, rpc.ErrorInvalidToken
+		}
+
+		return public, nil
+	}
+
+	signer := proxy.auth.VerifyRequest(request)
+	if signer != nil {
+		return false, nil
+	}
+
+	_, err := proxy.auth.Authorize(nil, nil, "PackageService.GetBus")
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (proxy *BusProxy) getPackage(
+	name string,
+	public bool,
+) (*proto.Package, error) {
+	query := bson.M{"name": name}
+	if public {
+		query["private"] = bson.M{"$ne": true}
+	}
+
+	var pkg proto.Package
+
+	err := proxy.collection.Find(query).One(&pkg)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, fmt.Errorf("no such package")
+		}
+
+		return nil, err
+	}
+
+	return &pkg, nil
+}
+
+// getInstances returns names of all instances that have ever built
+// packages and the current one.
+func (proxy *BusProxy) getInstances() ([]string, error) {
+	var instances []string
+
+	err := proxy.collection.Find(nil).Distinct("instance", &instances)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{proxy.instance}
+	for _, instance := range instances {
+		if instance != "" && instance != proxy.instance {
+			result = append(result, instance)
+		}
+	}
+
+	return result, nil
+}
+
+// relayBus copies messages from upstreams to the client until the client
+// goes away or all upstreams are closed.
+func relayBus(client *websocket.Conn, upstreams []*websocket.Conn) {
+	var (
+		mutex   = &sync.Mutex{}
+		relays  = &sync.WaitGroup{}
+		gone    = make(chan struct{})
+		stopped = make(chan struct{})
+	)
+
+	// client is not supposed to send anything, but reading is required to
+	// notice that it closed the connection
+	go func() {
+		defer close(gone)
+
+		for {
+			_, _, err := client.NextReader()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, upstream := range upstreams {
+		relays.Add(1)
+		go func(upstream *websocket.Conn) {
+			defer relays.Done()
+
+			for {
+				kind, data, err := upstream.ReadMessage()
+				if err != nil {
+					return
+				}
+
+				mutex.Lock()
+				err = client.WriteMessage(kind, data)
+				mutex.Unlock()
+
+				if err != nil {
+					return
+				}
+			}
+		}(upstream)
+	}
+
+	go func() {
+		relays.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-gone:
+	case <-stopped:
+	}
+
+	for _, upstream := range upstreams {
+		upstream.Close()
+	}
+
+	client.Close()
+
+	<-stopped
+	<-gone
+}
@@ -3,6 +3,9 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"net/url"
+	"path"
+	"strings"
 
 	"github.com/gorilla/websocket"
 	"github.com/kovetskiy/aurora/pkg/bus"
@@ -19,19 +22,97 @@ func NewBusServer(bus *Bus) *BusServer {
 	}
 }
 
+// busFilter is applied to the firehose topic, empty fields match
+// everything.
+type busFilter struct {
+	statuses []string
+	glob     string
+	owner    string
+	public   bool
+}
+
+func newBusFilter(query url.Values) busFilter {
+	filter := busFilter{
+		glob:   query.Get("glob"),
+		owner:  query.Get("owner"),
+		public: query.Get("public") != "",
+	}
+
+	for _, status := range query["status"] {
+		for _, value := range strings.Split(status, ",") {
+			if value != "" {
+				filter.statuses = append(filter.statuses, value)
+			}
+		}
+	}
+
+	return filter
+}
+
+func (filter busFilter) match(message interface{}) bool {
+	event, ok := message.(proto.Event)
+	if !ok {
+		return true
+	}
+
+	if filter.public && event.Private {
+		return false
+	}
+
+	if filter.owner != "" && event.Owner != filter.owner {
+		return false
+	}
+
+	if filter.glob != "" {
+		matched, err := path.Match(filter.glob, event.Package)
+		if err != nil || !matched {
+			return false
+		}
+	}
+
+	if len(filter.statuses) > 0 {
+		found := false
+		for _, status := range filter.statuses {
+			if event.Status == status {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (server *BusServer) ServeHTTP(
 	response http.ResponseWriter,
 	request *http.Request,
 ) {
 	query := request.URL.Query()
 
-	pkgName := query.Get("package")
-	if pkgName == "" {
+	var (
+		topic  string
+		filter busFilter
+	)
+
+	switch {
+	case query.Get("package") != "":
+		topic = query.Get("package")
+
+	case query.Get("all") != "":
+		topic = bus.TopicEvents
+		filter = newBusFilter(query)
+
+	default:
 		response.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	sub, exists := server.bus.Subscribe(pkgName)
+	sub, exists := server.bus.Subscribe(topic)
+	defer server.bus.Unsubscribe(topic, sub)
 
 	upgrader := &websocket.Upgrader{
 		ReadBufferSize:  1,
@@ -48,7 +129,7 @@ func (server *BusServer) ServeHTTP(
 
 	if !exists {
 		err := connection.WriteJSON(bus.Message{
-			Type: "empty_channel",
+			Type: bus.MessageTypeEmptyChannel,
 		})
 		if err != nil {
 			errorln(err)
@@ -56,42 +137,74 @@ func (server *BusServer) ServeHTTP(
 		}
 	}
 
+	// firehose is a live view, history of all packages is not interesting
+	if topic != bus.TopicEvents {
+		for _, message := range sub.Replay() {
+			err := connection.WriteJSON(newBusMessage(message))
+			if err != nil {
+				errorln(err)
+				return
+			}
+		}
+	}
+
 	for {
-		message, ok := <-sub
+		message, ok := <-sub.Messages()
 		if !ok {
-			tracef("sub for %s closed", pkgName)
+			tracef("sub for %s closed", topic)
 			break
 		}
 
-		switch data := message.(type) {
-		case proto.BuildStatus:
+		if dropped := sub.TakeDropped(); dropped > 0 {
 			err = connection.WriteJSON(bus.Message{
-				Type: "status",
-				Data: data.String(),
-			})
-
-		case string:
-			err = connection.WriteJSON(bus.Message{
-				Type: "log",
-				Data: data,
+				Type: bus.MessageTypeDropped,
+				Data: dropped,
 			})
+			if err != nil {
+				errorln(err)
+				return
+			}
+		}
 
-		default:
-			panic(
-				fmt.Errorf(
-					"unknown type of message in bus: %T %#v",
-					message,
-					message,
-				),
-			)
+		if !filter.match(message) {
+			continue
 		}
 
+		err = connection.WriteJSON(newBusMessage(message))
 		if err != nil {
 			errorln(err)
-			server.bus.Unsubscribe(pkgName, sub)
 			return
 		}
 	}
+}
+
+func newBusMessage(message interface{}) bus.Message {
+	switch data := message.(type) {
+	case proto.BuildStatus:
+		return bus.Message{
+			Type: bus.MessageTypeStatus,
+			Data: data.String(),
+		}
 
-	server.bus.Unsubscribe(pkgName, sub)
+	case string:
+		return bus.Message{
+			Type: bus.MessageTypeLog,
+			Data: data,
+		}
+
+	case proto.Event:
+		return bus.Message{
+			Type: bus.MessageTypeEvent,
+			Data: data,
+		}
+
+	default:
+		panic(
+			fmt.Errorf(
+				"unknown type of message in bus: %T %#v",
+				message,
+				message,
+			),
+		)
+	}
 }
@@ -6,11 +6,13 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
+	"github.com/kovetskiy/aurora/pkg/proto"
 	"github.com/reconquest/karma-go"
 )
 
@@ -38,19 +40,26 @@ func NewCloud(baseImage string, resources ConfigResources) (*Cloud, error) {
 func (cloud *Cloud) CreateContainer(
 	bufferDir string,
 	containerName string,
-	packageName string,
-	cloneURL string,
+	pkg proto.Package,
 ) (string, error) {
+	env := []string{}
+	for name, value := range pkg.Env {
+		env = append(env, fmt.Sprintf("%s=%s", name, value))
+	}
+
+	sort.Strings(env)
+
 	config := &container.Config{
 		Image: cloud.BaseImage,
 		Labels: map[string]string{
 			ImageLabelKey: version,
 		},
 		Tty: true,
-		Env: []string{
-			fmt.Sprintf("AURORA_PACKAGE=%s", packageName),
-			fmt.Sprintf("AURORA_CLONE_URL=%s", cloneURL),
-		},
+		Env: append(
+			env,
+			fmt.Sprintf("AURORA_PACKAGE=%s", pkg.Name),
+			fmt.Sprintf("AURORA_CLONE_URL=%s", pkg.CloneURL),
+		),
 		AttachStdout: true,
 		AttachStderr: true,
 	}
@@ -61,26 +70,40 @@ func (cloud *Cloud) CreateContainer(
 		},
 	}
 
-	if cloud.Resources.CPU > 0 {
+	cpu := cloud.Resources.CPU
+	if pkg.Resources != nil && pkg.Resources.CPU > 0 {
+		cpu = pkg.Resources.CPU
+	}
+
+	if cpu > 0 {
 		hostConfig.Resources.CPUPeriod = 1000000
 		hostConfig.Resources.CPUQuota = int64(
-			float64(hostConfig.Resources.CPUPeriod) * cloud.Resources.CPU,
+			float64(hostConfig.Resources.CPUPeriod) * cpu,
 		)
 	}
 
+	if pkg.Resources != nil && pkg.Resources.Memory > 0 {
+		hostConfig.Resources.Memory = pkg.Resources.Memory
+	}
+
 	created, err := cloud.client.ContainerCreate(
 		context.Background(), config,
 		hostConfig, nil, containerName,
 	)
 	if err != nil {
+		metricContainerErrors.WithLabelValues("create").Inc()
 		return "", err
 	}
 
 	return created.ID, nil
 }
 
-func (cloud *Cloud) WaitContainer(name string) (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*30)
+func (cloud *Cloud) WaitContainer(
+	parent context.Context,
+	name string,
+	timeout time.Duration,
+) (bool, error) {
+	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
 
 	wait, _ := cloud.client.ContainerWait(
@@ -95,6 +118,10 @@ func (cloud *Cloud) WaitContainer(name string) (bool, error) {
 		}
 		return false, nil
 	case <-ctx.Done():
+		if parent.Err() != nil {
+			return false, parent.Err()
+		}
+
 		return true, nil
 	}
 }
@@ -137,6 +164,7 @@ func (cloud *Cloud) StartContainer(container string) error {
 		types.ContainerStartOptions{},
 	)
 	if err != nil {
+		metricContainerErrors.WithLabelValues("start").Inc()
 		return err
 	}
 
@@ -159,6 +187,7 @@ func (cloud *Cloud) DestroyContainer(container string) error {
 		},
 	)
 	if err != nil {
+		metricContainerErrors.WithLabelValues("destroy").Inc()
 		return err
 	}
 
@@ -219,6 +248,16 @@ func (cloud *Cloud) WriteLogs(
 	return nil
 }
 
+// Ping checks that docker daemon is reachable.
+func (cloud *Cloud) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := cloud.client.Ping(ctx)
+
+	return err
+}
+
 func (cloud *Cloud) Cleanup() error {
 	options := types.ContainerListOptions{}
 
@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"github.com/go-yaml/yaml"
+	"github.com/kovetskiy/aurora/pkg/classify"
+	"github.com/kovetskiy/aurora/pkg/mail"
+	"github.com/kovetskiy/aurora/pkg/webhook"
 	"github.com/kovetskiy/ko"
 	"github.com/reconquest/karma-go"
 )
@@ -25,9 +28,33 @@ listen: ":80"
 # DSN of database to use (mongodb)
 database: "mongodb://localhost/aurora"
 
-# directory with ready-to-install packages
+# directory with ready-to-install packages, every repository is stored in
+# its own subdirectory
 repo_dir: "/srv/http/aurora/"
 
+# pacman repositories, repository is stored in repo_dir/<name>/ with database
+# <name>.db.tar and served at /<name>/, so use
+# Server = https://aurora.example.com/$repo in pacman.conf
+repos:
+  aurora-testing:
+    # GPG key to sign packages and the database with, empty = no signing
+    key: ""
+    # packages are promoted from this repository to specified one by
+    # 'aurora promote' or automatically after soak period
+    promote_to: "aurora"
+    # promote automatically if no problems were reported with 'aurora report'
+    # during specified time since the version was published, 0 = by hand only
+    soak: "48h"
+  aurora:
+    key: ""
+    # overrides global history settings for this repository
+    # history:
+    #   versions: 3
+    #   builds_per_version: 3
+
+# repositories of packages added without specifying any
+default_repos: ["aurora-testing"]
+
 # directory where logs will be stored
 logs_dir: "/var/log/aurora/packages/"
 
@@ -44,17 +71,48 @@ interval:
   # how often should poll queue
   poll: "2s"
   build:
-    # rebuild if stuck in processing more than specified time
+    # rebuild if stuck in processing more than specified time or the build
+    # timeout of the package if it's longer
     status_processing: "30m"
     # rebuild if succeeded more than specified time
     status_success: "30m"
     # rebuild if failed more than specified time
     status_failure: "60m"
+  # packages can have own schedule (aurora add --schedule), this is how
+  # often upstream of packages scheduled with "upstream" is checked
+  upstream: "15m"
+
+scheduling:
+  # packages are dispatched by priority, every specified time of waiting for
+  # a build raises priority of a package by one, so low priority packages
+  # are not starved; 0 = disabled
+  aging: "1h"
+  # resource classes of packages (aurora set <package> class=heavy) with
+  # limits of concurrent builds of the class on one instance, 0 = unlimited;
+  # packages without class are limited only by threads
+  classes:
+    heavy:
+      limit: 1
+    light:
+      limit: 0
+  # consecutive failures of a package double the interval between builds
+  # starting from interval.build.status_failure up to specified one;
+  # 0 = failed packages are retried every status_failure
+  backoff: "24h"
+  # after specified number of consecutive failures the package is
+  # quarantined: it's rebuilt only on upstream change or aurora rebuild;
+  # 0 = disabled
+  quarantine: 10
 
 timeout:
   # give up building process
   build: "30m"
 
+shutdown:
+  # on SIGTERM or SIGINT stop taking new builds and wait for running ones
+  # for specified time, then cancel them; second signal exits immediately
+  grace: "5m"
+
 # image used for building pkgs
 base_image: "aurora"
 
@@ -68,10 +126,82 @@ history:
 # bus server is an event pubsub system inside of aurorad
 bus:
 	listen: ":4242"
+	# how many messages can be queued for a single subscriber
+	queue: 1024
+	# what to do with subscriber which queue is full: drop or disconnect
+	policy: "drop"
+	# how many last messages of a topic to replay for new subscribers
+	history: 10000
 
 # dir with authorized RSA public keys
 authorized_keys: "/etc/aurora/authorized_keys"
 
+# names of keys in authorized_keys which can pause and resume the queue,
+# every key can if empty
+admins: []
+
+# secret for signing short-lived tokens (e.g. for watching builds through
+# the web server), random secret is generated on every start if empty
+token_secret: ""
+
+# allow unsigned callers to use specified read-only methods, available are:
+# PackageService.ListPackages, PackageService.GetPackage,
+# PackageService.GetLogs, PackageService.GetBus, PackageService.ListBuilds,
+# PackageService.GetQueue and AdminService.GetStatus;
+# private packages are never shown to anonymous callers
+anonymous:
+  methods: []
+
+# outgoing webhooks for package events, every event is POSTed as JSON
+webhooks:
+  # how many times to try to deliver an event
+  retries: 5
+  # delay before the second attempt, doubled for each next one
+  backoff: "10s"
+  hooks: []
+  # - url: "https://example.com/aurora"
+  #   # signs body with HMAC-SHA256 into X-Aurora-Webhook-Signature header
+  #   secret: ""
+  #   # status, build_started, build_finished, version_changed,
+  #   # package_added, package_removed, package_promoted,
+  #   # package_quarantined; empty = all events
+  #   events: ["status", "version_changed"]
+  #   # package name globs, empty = all packages
+  #   packages: ["*-git"]
+  #   # package statuses, empty = any status
+  #   statuses: ["failure"]
+
+# mail notifications, disabled if smtp address is empty
+mail:
+  smtp:
+    # host:port of the SMTP relay
+    address: ""
+    from: "aurora@localhost"
+    username: ""
+    password: ""
+  # how many last lines of the build log to attach to failure mail
+  log_tail: 100
+  # time of day to send the digest of failures and version changes at
+  digest_at: "09:00"
+  # notification preferences, keys are names of keys in authorized_keys
+  users: {}
+  #  alice:
+  #    email: "alice@example.com"
+  #    # mail when build of alice's package goes from success to failure
+  #    failures: true
+  #    # receive the daily digest
+  #    digest: true
+
+# failed builds are classified by rules matched against every line of the
+# build log; rules listed here are checked before built-in ones which
+# recognise missing_dependency, checksum, download, compile, disk_full and
+# timeout failures
+classify:
+  rules: []
+  # - category: "network"
+  #   # regular expression
+  #   pattern: "Could not resolve host"
+
 # resources limitation for build containers
 resources:
 	cpu: 0 # fractional number of cpu shares to allow for single container, 0 = unlimited
@@ -82,6 +212,48 @@ type ConfigHistory struct {
 	BuildsPerVersion int `yaml:"builds_per_version" required:"true"`
 }
 
+type ConfigRepository struct {
+	Key       string         `yaml:"key"`
+	History   *ConfigHistory `yaml:"history"`
+	PromoteTo string         `yaml:"promote_to"`
+	Soak      time.Duration  `yaml:"soak"`
+}
+
+type ConfigBus struct {
+	Listen  string `yaml:"listen" required:"true"`
+	Queue   int    `yaml:"queue"`
+	Policy  string `yaml:"policy"`
+	History int    `yaml:"history"`
+}
+
+type ConfigWebhooks struct {
+	Retries int            `yaml:"retries"`
+	Backoff time.Duration  `yaml:"backoff"`
+	Hooks   []webhook.Hook `yaml:"hooks"`
+}
+
+type ConfigMail struct {
+	SMTP     mail.SMTP            `yaml:"smtp"`
+	LogTail  int                  `yaml:"log_tail"`
+	DigestAt string               `yaml:"digest_at"`
+	Users    map[string]mail.User `yaml:"users"`
+}
+
+type ConfigScheduling struct {
+	Aging      time.Duration          `yaml:"aging"`
+	Classes    map[string]ConfigClass `yaml:"classes"`
+	Backoff    time.Duration          `yaml:"backoff"`
+	Quarantine int                    `yaml:"quarantine"`
+}
+
+type ConfigClass struct {
+	Limit int `yaml:"limit"`
+}
+
+type ConfigClassify struct {
+	Rules []classify.Rule `yaml:"rules"`
+}
+
 type ConfigResources struct {
 	CPU float64 `yaml:"cpu"`
 }
@@ -100,25 +272,43 @@ type Config struct {
 	BaseImage string        `yaml:"base_image" required:"true"`
 	History   ConfigHistory `yaml:"history" required:"true"`
 
-	Bus struct {
-		Listen string `yaml:"listen" required:"true"`
-	} `required:"true"`
+	Repos        map[string]ConfigRepository `yaml:"repos"`
+	DefaultRepos []string                    `yaml:"default_repos"`
+
+	Bus ConfigBus `required:"true"`
 
 	Interval struct {
-		Poll  time.Duration `yaml:"poll" required:"true"`
-		Build struct {
+		Poll     time.Duration `yaml:"poll" required:"true"`
+		Upstream time.Duration `yaml:"upstream"`
+		Build    struct {
 			StatusProcessing time.Duration `yaml:"status_processing" required:"true"`
 			StatusSuccess    time.Duration `yaml:"status_success" required:"true"`
 			StatusFailure    time.Duration `yaml:"status_failure" required:"true"`
 		} `required:"true"`
 	} `required:"true"`
 
+	Scheduling ConfigScheduling `yaml:"scheduling"`
+
 	Timeout struct {
 		Build string `yaml:"build" required:"true"`
 	} `required:"true"`
 
+	Shutdown struct {
+		Grace time.Duration `yaml:"grace"`
+	} `yaml:"shutdown"`
+
 	Resources         ConfigResources
-	AuthorizedKeysDir string `yaml:"authorized_keys" required:"true"`
+	AuthorizedKeysDir string   `yaml:"authorized_keys" required:"true"`
+	Admins            []string `yaml:"admins"`
+	TokenSecret       string   `yaml:"token_secret"`
+
+	Anonymous struct {
+		Methods []string `yaml:"methods"`
+	} `yaml:"anonymous"`
+
+	Webhooks ConfigWebhooks `yaml:"webhooks"`
+	Mail     ConfigMail     `yaml:"mail"`
+	Classify ConfigClassify `yaml:"classify"`
 }
 
 func GenerateConfig(path string) error {
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/kovetskiy/aurora/pkg/proto"
+)
+
+// feedLimit is how many last published versions are listed in the feed.
+const feedLimit = 100
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+}
+
+// serveFeed renders Atom feed of versions published into the repository.
+func (ui *UI) serveFeed(response http.ResponseWriter, request *http.Request) {
+	signer, err := ui.authorize(request, "PackageService.ListPackages")
+	if err != nil {
+		http.Error(response, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	releases, err := ui.builds.FindReleases(feedLimit)
+	if err != nil {
+		errorh(err, "unable to find releases for feed")
+
+		http.Error(response, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	hidden := map[string]bool{}
+	if signer == nil {
+		var private []string
+
+		err = ui.collection.Find(bson.M{"private": true}).
+			Distinct("name", &private)
+		if err != nil {
+			errorh(err, "unable to find private packages for feed")
+
+			http.Error(response, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		for _, name := range private {
+			hidden[name] = true
+		}
+	}
+
+	base := getBaseURL(request)
+
+	feed := atomFeed{
+		ID:    base + uiPrefix + "/feed.atom",
+		Title: "aurora: new versions",
+		Link: []atomLink{
+			{Href: base + uiPrefix + "/feed.atom", Rel: "self"},
+			{Href: base + uiPrefix + "/", Rel: "alternate", Type: "text/html"},
+		},
+		Updated: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	for _, release := range releases {
+		if hidden[release.Package] {
+			continue
+		}
+
+		page := base + uiPrefix + "/packages/" + url.PathEscape(release.Package)
+
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      page + "#" + url.QueryEscape(release.Version),
+			Title:   release.Package + " " + release.Version,
+			Updated: release.Finished.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: page + "/logs", Rel: "alternate"},
+			Summary: formatRelease(release),
+		})
+	}
+
+	if len(feed.Entries) > 0 {
+		feed.Updated = feed.Entries[0].Updated
+	}
+
+	response.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+
+	_, err = response.Write([]byte(xml.Header))
+	if err != nil {
+		return
+	}
+
+	encoder := xml.NewEncoder(response)
+	encoder.Indent("", "  ")
+
+	err = encoder.Encode(feed)
+	if err != nil {
+		errorh(err, "unable to write feed")
+	}
+}
+
+func formatRelease(release proto.Build) string {
+	return release.Package + " " + release.Version +
+		" has been built at " + release.Instance +
+		" in " + release.Duration.Round(time.Second).String()
+}
+
+// getBaseURL returns scheme and host the request was sent to, taking into
+// account reverse proxy headers.
+func getBaseURL(request *http.Request) string {
+	scheme := "http"
+	if request.TLS != nil {
+		scheme = "https"
+	}
+
+	if forwarded := request.Header.Get("X-Forwarded-Proto"); forwarded != "" {
+		scheme = forwarded
+	}
+
+	return scheme + "://" + request.Host
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-systemd/daemon"
+	"github.com/globalsign/mgo"
+	"github.com/reconquest/karma-go"
+)
+
+// maxRepoLockAge is how long repo-add lock can be held before it's
+// considered stale.
+const maxRepoLockAge = 10 * time.Minute
+
+type healthCheck struct {
+	name  string
+	check func() error
+	live  bool
+}
+
+// Health runs checks for /healthz (liveness) and /readyz (readiness)
+// endpoints and pings systemd watchdog while liveness checks pass.
+type Health struct {
+	mutex  *sync.Mutex
+	checks []healthCheck
+}
+
+func NewHealth() *Health {
+	return &Health{
+		mutex: &sync.Mutex{},
+	}
+}
+
+// AddReady adds check which is required for the service to be ready.
+func (health *Health) AddReady(name string, check func() error) {
+	health.mutex.Lock()
+	defer health.mutex.Unlock()
+
+	health.checks = append(health.checks, healthCheck{name: name, check: check})
+}
+
+// AddLive adds check which is failed only when the service is wedged and
+// has to be restarted, such checks are also required for readiness.
+func (health *Health) AddLive(name string, check func() error) {
+	health.mutex.Lock()
+	defer health.mutex.Unlock()
+
+	health.checks = append(
+		health.checks,
+		healthCheck{name: name, check: check, live: true},
+	)
+}
+
+func (health *Health) run(liveOnly bool) (map[string]string, bool) {
+	health.mutex.Lock()
+	checks := health.checks
+	health.mutex.Unlock()
+
+	var (
+		ok      = true
+		results = map[string]string{}
+	)
+
+	for _, check := range checks {
+		if liveOnly && !check.live {
+			continue
+		}
+
+		err := check.check()
+		if err != nil {
+			ok = false
+			results[check.name] = err.Error()
+		} else {
+			results[check.name] = "ok"
+		}
+	}
+
+	return results, ok
+}
+
+func (health *Health) ServeLive(writer http.ResponseWriter, request *http.Request) {
+	health.serve(writer, true)
+}
+
+func (health *Health) ServeReady(writer http.ResponseWriter, request *http.Request) {
+	health.serve(writer, false)
+}
+
+func (health *Health) serve(writer http.ResponseWriter, liveOnly bool) {
+	results, ok := health.run(liveOnly)
+
+	writer.Header().Set("Content-Type", "application/json")
+	if !ok {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	err := json.NewEncoder(writer).Encode(results)
+	if err != nil {
+		errorh(err, "unable to write health check results")
+	}
+}
+
+// NotifySystemd tells systemd that the service is ready and then pings
+// watchdog (if WatchdogSec is set in the unit) while liveness checks pass.
+func (health *Health) NotifySystemd() {
+	sent, err := daemon.SdNotify(false, daemon.SdNotifyReady)
+	if err != nil {
+		errorh(err, "unable to notify systemd about readiness")
+	}
+
+	if !sent {
+		return
+	}
+
+	interval, err := daemon.SdWatchdogEnabled(false)
+	if err != nil {
+		errorh(err, "unable to get systemd watchdog interval")
+		return
+	}
+
+	if interval == 0 {
+		return
+	}
+
+	infof("pinging systemd watchdog every %s", interval/2)
+
+	for {
+		time.Sleep(interval / 2)
+
+		results, ok := health.run(true)
+		if !ok {
+			warningf("liveness checks failed, not pinging watchdog: %v", results)
+			continue
+		}
+
+		_, err := daemon.SdNotify(false, daemon.SdNotifyWatchdog)
+		if err != nil {
+			errorh(err, "unable to ping systemd watchdog")
+		}
+	}
+}
+
+func checkDatabase(collection *mgo.Collection) func() error {
+	return func() error {
+		return collection.Database.Session.Ping()
+	}
+}
+
+func checkDirWritable(dir string) func() error {
+	return func() error {
+		file, err := ioutil.TempFile(dir, ".aurora-health-")
+		if err != nil {
+			return karma.Format(err, "directory is not writable: %s", dir)
+		}
+
+		file.Close()
+
+		return os.Remove(file.Name())
+	}
+}
+
+// addRepositoryChecks adds readiness checks of every repository.
+func addRepositoryChecks(health *Health, repos []*Repository) {
+	for _, repo := range repos {
+		health.AddReady("repo_dir:"+repo.Name, checkDirWritable(repo.Dir))
+		health.AddReady("repo_lock:"+repo.Name, checkRepoLock(repo))
+	}
+}
+
+// checkRepoLock fails if repo-add lock file is held for too long.
+func checkRepoLock(repo *Repository) func() error {
+	return func() error {
+		path := repo.Database() + ".lck"
+
+		stat, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		age := time.Since(stat.ModTime())
+		if age > maxRepoLockAge {
+			return fmt.Errorf("lock %s is held for %s", path, age)
+		}
+
+		return nil
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/user"
 	"strconv"
 	"text/tabwriter"
 	"time"
@@ -11,8 +12,15 @@ import (
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 	"github.com/kovetskiy/aur-go"
+	"github.com/kovetskiy/aurora/pkg/audit"
+	"github.com/kovetskiy/aurora/pkg/history"
+	"github.com/kovetskiy/aurora/pkg/pause"
 	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/schedule"
+	"github.com/kovetskiy/aurora/pkg/staging"
+	"github.com/kovetskiy/aurora/pkg/webhook"
 	"github.com/kovetskiy/lorg"
+	"github.com/reconquest/karma-go"
 )
 
 var (
@@ -21,7 +29,8 @@ var (
 
 Usage:
   aurorad [options] -L
-  aurorad [options] -A <package>... [-p <priority>]
+  aurorad [options] -A <package>... [-p <priority>] [--private] [--repo <name>]...
+                      [--schedule <spec>]
   aurorad [options] -R <package>...
   aurorad [options] -Q
   aurorad [options] -P
@@ -38,6 +47,11 @@ Options:
   -c --config <path>  Configuration file path.
                        [default: ` + defaultConfigPath + `]
   -p --priority <n>   Priority level of the package [default: 0].
+  --private           Hide the package from anonymous callers.
+  --repo <name>       Publish the package to specified repository instead of
+                       default ones.
+  --schedule <spec>   Rebuild the package by cron expression, "every <interval>"
+                       or only on "upstream" change instead of global intervals.
   -h --help           Show this screen.
   --version           Show version.
 `
@@ -108,22 +122,57 @@ func main() {
 		fatalh(err, "can't ensure index for collection")
 	}
 
+	trail, err := audit.NewTrail(database.C("audit"))
+	if err != nil {
+		fatalh(err, "can't initialize audit trail")
+	}
+
+	builds, err := history.NewHistory(database.C("builds"))
+	if err != nil {
+		fatalh(err, "can't initialize builds history")
+	}
+
+	stages, err := staging.NewStaging(database.C("staging"))
+	if err != nil {
+		fatalh(err, "can't initialize staging")
+	}
+
+	pauses := pause.NewQueue(database.C("settings"))
+
+	notifier := webhook.NewDispatcher(
+		config.Webhooks.Hooks,
+		database.C("webhook_deliveries"),
+		config.Webhooks.Retries,
+		config.Webhooks.Backoff,
+		logger,
+	)
+
 	switch {
 	case args["--add"].(bool):
 		priority, _ := strconv.Atoi(args["--priority"].(string))
-		err = addPackage(packages, args["<package>"].([]string), priority)
+		spec, _ := args["--schedule"].(string)
+		err = addPackage(
+			packages,
+			trail,
+			args["<package>"].([]string),
+			priority,
+			args["--private"].(bool),
+			args["--repo"].([]string),
+			spec,
+			config,
+		)
 
 	case args["--remove"].(bool):
-		err = removePackage(packages, args["<package>"].([]string))
+		err = removePackage(packages, trail, args["<package>"].([]string))
 
 	case args["--process"].(bool):
-		err = processQueue(packages, config)
+		err = processQueue(packages, builds, stages, pauses, notifier, config)
 
 	case args["--query"].(bool):
 		err = queryPackage(packages)
 
 	case args["--listen"].(bool):
-		err = serveWeb(packages, config)
+		err = serveWeb(packages, trail, builds, stages, pauses, notifier, config)
 	}
 
 	if err != nil {
@@ -131,18 +180,62 @@ func main() {
 	}
 }
 
-func addPackage(collection *mgo.Collection, packages []string, priority int) error {
-	var err error
+func addPackage(
+	collection *mgo.Collection,
+	trail *audit.Trail,
+	packages []string,
+	priority int,
+	private bool,
+	repos []string,
+	spec string,
+	config *Config,
+) error {
+	available, err := getRepositories(config)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := describeRepositories(available, config).Resolve(repos)
+	if err != nil {
+		return karma.Format(err, "unable to use repositories %v", repos)
+	}
+
+	rebuild, err := schedule.Parse(spec)
+	if err != nil {
+		return karma.Format(err, "invalid schedule")
+	}
 
 	for _, name := range packages {
+		now := time.Now()
+
 		err = collection.Insert(
 			proto.Package{
 				Name:     name,
 				Status:   proto.BuildStatusQueued.String(),
-				Date:     time.Now(),
+				Date:     now,
+				Next:     now,
 				Priority: priority,
+				Private:  private,
+				Owner:    getLocalSigner(),
+				Repos:    resolved,
+				Schedule: rebuild,
+			},
+		)
+
+		auditErr := auditLocal(
+			trail, "aurorad -A",
+			map[string]interface{}{
+				"name":     name,
+				"priority": priority,
+				"private":  private,
+				"repos":    resolved,
+				"schedule": spec,
 			},
+			err,
 		)
+		if auditErr != nil {
+			return auditErr
+		}
 
 		if err == nil {
 			infof("package %s has been added", name)
@@ -156,7 +249,11 @@ func addPackage(collection *mgo.Collection, packages []string, priority int) err
 	return nil
 }
 
-func removePackage(collection *mgo.Collection, packages []string) error {
+func removePackage(
+	collection *mgo.Collection,
+	trail *audit.Trail,
+	packages []string,
+) error {
 	var err error
 
 	for _, name := range packages {
@@ -164,6 +261,17 @@ func removePackage(collection *mgo.Collection, packages []string) error {
 			bson.M{"name": name},
 		)
 
+		auditErr := auditLocal(
+			trail, "aurorad -R",
+			map[string]interface{}{
+				"name": name,
+			},
+			err,
+		)
+		if auditErr != nil {
+			return auditErr
+		}
+
 		if err == nil {
 			infof("package %s has been removed", name)
 		} else if err == mgo.ErrNotFound {
@@ -176,6 +284,38 @@ func removePackage(collection *mgo.Collection, packages []string) error {
 	return nil
 }
 
+// auditLocal records action performed through aurorad command line on the
+// server.
+func auditLocal(
+	trail *audit.Trail,
+	method string,
+	params map[string]interface{},
+	result error,
+) error {
+	err := trail.Record(getLocalSigner(), method, "local", params, result)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to record action into audit trail",
+		)
+	}
+
+	return nil
+}
+
+// getLocalSigner returns name used instead of signer for actions performed
+// through aurorad command line.
+func getLocalSigner() string {
+	local, err := user.Current()
+	if err != nil {
+		warningh(err, "unable to get current user")
+
+		return "aurorad"
+	}
+
+	return local.Username + "@aurorad"
+}
+
 func queryPackage(collection *mgo.Collection) error {
 	var (
 		pkg      = proto.Package{}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	jsonrpc "github.com/gorilla/rpc/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "aurora"
+
+var (
+	metricBuildDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "build_duration_seconds",
+			Help:      "Duration of package builds by resulting status.",
+			Buckets: []float64{
+				30, 60, 120, 300, 600, 900, 1200, 1800, 2700, 3600,
+			},
+		},
+		[]string{"status"},
+	)
+
+	metricBuilds = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "builds_total",
+			Help:      "Finished builds by package and resulting status.",
+		},
+		[]string{"package", "status"},
+	)
+
+	metricThreads = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "threads",
+			Help:      "Size of the build thread pool.",
+		},
+	)
+
+	metricThreadsBusy = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "threads_busy",
+			Help:      "Threads of the build thread pool running builds.",
+		},
+	)
+
+	metricContainerErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "container_errors_total",
+			Help:      "Errors of docker operations by operation.",
+		},
+		[]string{"operation"},
+	)
+
+	metricRPCDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "rpc_duration_seconds",
+			Help:      "Latency of RPC calls by method.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"method"},
+	)
+
+	metricRPCErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "rpc_errors_total",
+			Help:      "RPC calls which returned error by method.",
+		},
+		[]string{"method"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		metricBuildDuration,
+		metricBuilds,
+		metricThreads,
+		metricThreadsBusy,
+		metricContainerErrors,
+		metricRPCDuration,
+		metricRPCErrors,
+	)
+}
+
+// queueCollector exports amount of packages in every status, it queries
+// database on every scrape.
+type queueCollector struct {
+	storage *mgo.Collection
+	depth   *prometheus.Desc
+}
+
+func newQueueCollector(storage *mgo.Collection) *queueCollector {
+	return &queueCollector{
+		storage: storage,
+		depth: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "queue_packages"),
+			"Packages in the queue by status.",
+			[]string{"status"},
+			nil,
+		),
+	}
+}
+
+func (collector *queueCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- collector.depth
+}
+
+func (collector *queueCollector) Collect(metrics chan<- prometheus.Metric) {
+	var groups []struct {
+		Status string `bson:"_id"`
+		Count  int    `bson:"count"`
+	}
+
+	err := collector.storage.Pipe([]bson.M{
+		{"$group": bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}},
+	}).All(&groups)
+	if err != nil {
+		errorh(err, "unable to count packages by status for metrics")
+		return
+	}
+
+	for _, group := range groups {
+		metrics <- prometheus.MustNewConstMetric(
+			collector.depth,
+			prometheus.GaugeValue,
+			float64(group.Count),
+			group.Status,
+		)
+	}
+}
+
+// registerCommonMetrics registers metrics exported by both web and
+// processor.
+func registerCommonMetrics(storage *mgo.Collection, config *Config) {
+	prometheus.MustRegister(newQueueCollector(storage))
+
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "repo_size_bytes",
+			Help:      "Total size of files in the repository directory.",
+		},
+		func() float64 {
+			return float64(getDirSize(config.RepoDir))
+		},
+	))
+}
+
+func registerBusMetrics(bus *Bus) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "bus_subscribers",
+			Help:      "Subscribers of the bus across all topics.",
+		},
+		func() float64 {
+			return float64(bus.CountSubscribers())
+		},
+	))
+}
+
+// instrumentRPC measures latency of every RPC call.
+func instrumentRPC(server *jsonrpc.Server) {
+	started := &sync.Map{}
+
+	server.RegisterBeforeFunc(func(info *jsonrpc.RequestInfo) {
+		started.Store(info.Request, time.Now())
+	})
+
+	server.RegisterAfterFunc(func(info *jsonrpc.RequestInfo) {
+		value, ok := started.Load(info.Request)
+		if !ok {
+			return
+		}
+
+		started.Delete(info.Request)
+
+		metricRPCDuration.WithLabelValues(info.Method).Observe(
+			time.Since(value.(time.Time)).Seconds(),
+		)
+
+		if info.Error != nil {
+			metricRPCErrors.WithLabelValues(info.Method).Inc()
+		}
+	})
+}
+
+func getDirSize(dir string) int64 {
+	var size int64
+
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if !info.IsDir() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/reconquest/karma-go"
+)
+
+// pin republishes archived builds of packages which have pending pins.
+func (proc *Processor) pin() {
+	packages := []proto.Package{}
+
+	err := proc.storage.Find(bson.M{"pin.pending": true}).All(&packages)
+	if err != nil {
+		errorh(err, "unable to find pinned packages")
+		return
+	}
+
+	for _, pkg := range packages {
+		if proc.registry.isClosed() {
+			return
+		}
+
+		pinning := proc.newBuild(pkg)
+		pinning.init()
+
+		update := bson.M{
+			"pin.pending": false,
+			"pin.error":   "",
+		}
+
+		archive, err := pinning.republish(*pkg.Pin, proc.repos)
+		if err != nil {
+			pinning.log.Error(err)
+
+			update["pin.error"] = err.Error()
+		} else {
+			update["version"] = pkg.Pin.Version
+			update["archive"] = archive
+		}
+
+		err = proc.storage.Update(
+			bson.M{"name": pkg.Name},
+			bson.M{"$set": update},
+		)
+		if err != nil {
+			pinning.log.Error(
+				karma.Format(err, "unable to update pin of package"),
+			)
+		}
+	}
+}
+
+// republishing is an archived build which is going to be republished in
+// the repository, the archive is copied into the repository first if it's
+// stored in another one.
+type republishing struct {
+	repo *Repository
+	path string
+}
+
+// republish points databases of the package repositories at the archived
+// build and returns basename of its archive. Stable repositories which the
+// package has been promoted to are republished as well, so users of the
+// stable repository get the pinned build too.
+func (build *build) republish(
+	pin proto.Pin,
+	repos []*Repository,
+) (string, error) {
+	plan, err := getRepublishing(build.repos, repos, build.pkg.Name, pin)
+	if err != nil {
+		return "", err
+	}
+
+	var basename string
+	for _, item := range plan {
+		if filepath.Dir(item.path) != item.repo.Dir {
+			build.log.Infof(
+				"copying %s to %s repository", item.path, item.repo,
+			)
+
+			err = build.addToRepository(item.repo, item.path)
+			if err != nil {
+				return "", err
+			}
+		} else {
+			build.log.Infof(
+				"republishing %s in %s repository", item.path, item.repo,
+			)
+
+			err = build.repoAdd(item.repo, item.path)
+			if err != nil {
+				return "", karma.Format(
+					err, "can't update %s repository", item.repo,
+				)
+			}
+		}
+
+		basename = filepath.Base(item.path)
+	}
+
+	if basename != build.pkg.Archive {
+		event := proto.NewEvent(proto.EventVersionChanged, build.pkg)
+		event.Version = pin.Version
+		event.PreviousVersion = build.pkg.Version
+
+		build.publishEvent(event)
+	}
+
+	return basename, nil
+}
+
+// getRepublishing returns archives of the pin for repositories of the
+// package and for repositories they are promoted to if the package has
+// been promoted there. Archive of the testing repository is used for the
+// stable one if the pinned build has not been promoted.
+func getRepublishing(
+	assigned []*Repository,
+	repos []*Repository,
+	name string,
+	pin proto.Pin,
+) ([]republishing, error) {
+	if len(assigned) == 0 {
+		return nil, errors.New("package is not assigned to any repository")
+	}
+
+	plan := []republishing{}
+	planned := map[string]bool{}
+
+	for _, repo := range assigned {
+		path, err := findPinnedArchive(repo, name, pin)
+		if err != nil {
+			return nil, err
+		}
+
+		plan = append(plan, republishing{repo: repo, path: path})
+		planned[repo.Name] = true
+	}
+
+	for _, source := range plan[:len(assigned)] {
+		if source.repo.PromoteTo == "" || planned[source.repo.PromoteTo] {
+			continue
+		}
+
+		found := findRepositories(repos, []string{source.repo.PromoteTo})
+		if found == nil {
+			continue
+		}
+
+		target := found[0]
+
+		promoted, err := hasArchive(target, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if !promoted {
+			continue
+		}
+
+		path, err := findPinnedArchive(target, name, pin)
+		if err != nil {
+			path = source.path
+		}
+
+		plan = append(plan, republishing{repo: target, path: path})
+		planned[target.Name] = true
+	}
+
+	return plan, nil
+}
+
+func findPinnedArchive(
+	repo *Repository,
+	name string,
+	pin proto.Pin,
+) (string, error) {
+	if pin.Archive == "" {
+		return findArchive(repo, name, pin.Version)
+	}
+
+	path := filepath.Join(repo.Dir, pin.Archive)
+
+	_, err := os.Stat(path)
+	if err != nil {
+		return "", karma.Format(
+			err,
+			"archive %s not found in %s repository", pin.Archive, repo,
+		)
+	}
+
+	return path, nil
+}
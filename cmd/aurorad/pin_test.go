@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRepublishing(t *testing.T) {
+	test := assert.New(t)
+
+	root, err := ioutil.TempDir("", "aurora-pin")
+	test.NoError(err)
+	defer os.RemoveAll(root)
+
+	testingRepo := &Repository{
+		Name:      "aurora-testing",
+		Dir:       filepath.Join(root, "aurora-testing"),
+		PromoteTo: "aurora",
+	}
+	stableRepo := &Repository{
+		Name: "aurora",
+		Dir:  filepath.Join(root, "aurora"),
+	}
+	repos := []*Repository{stableRepo, testingRepo}
+
+	archives := map[*Repository][]string{
+		testingRepo: {
+			"1.foo-1.0-1-any.pkg.tar.xz",
+			"2.foo-1.1-1-any.pkg.tar.xz",
+			"3.foo-1.2-1-any.pkg.tar.xz",
+			"4.bar-1.0-1-any.pkg.tar.xz",
+		},
+		// 1.1-1 is broken and has been promoted, 1.2-1 is soaking
+		stableRepo: {
+			"1.foo-1.0-1-any.pkg.tar.xz",
+			"2.foo-1.1-1-any.pkg.tar.xz",
+		},
+	}
+
+	for repo, names := range archives {
+		test.NoError(os.MkdirAll(repo.Dir, 0755))
+
+		for _, name := range names {
+			test.NoError(
+				ioutil.WriteFile(filepath.Join(repo.Dir, name), nil, 0644),
+			)
+		}
+	}
+
+	testcases := []struct {
+		Name     string
+		Assigned []*Repository
+		Pin      proto.Pin
+		Plan     []republishing
+	}{
+		{
+			// rollback
+			"foo",
+			[]*Repository{testingRepo},
+			proto.Pin{Version: "1.0-1", Archive: "1.foo-1.0-1-any.pkg.tar.xz"},
+			[]republishing{
+				{testingRepo, filepath.Join(testingRepo.Dir, "1.foo-1.0-1-any.pkg.tar.xz")},
+				{stableRepo, filepath.Join(stableRepo.Dir, "1.foo-1.0-1-any.pkg.tar.xz")},
+			},
+		},
+		{
+			// pin of a version which has not been promoted yet
+			"foo",
+			[]*Repository{testingRepo},
+			proto.Pin{Version: "1.2-1"},
+			[]republishing{
+				{testingRepo, filepath.Join(testingRepo.Dir, "3.foo-1.2-1-any.pkg.tar.xz")},
+				{stableRepo, filepath.Join(testingRepo.Dir, "3.foo-1.2-1-any.pkg.tar.xz")},
+			},
+		},
+		{
+			// package assigned to both repositories
+			"foo",
+			[]*Repository{testingRepo, stableRepo},
+			proto.Pin{Version: "1.0-1"},
+			[]republishing{
+				{testingRepo, filepath.Join(testingRepo.Dir, "1.foo-1.0-1-any.pkg.tar.xz")},
+				{stableRepo, filepath.Join(stableRepo.Dir, "1.foo-1.0-1-any.pkg.tar.xz")},
+			},
+		},
+		{
+			// package which has never been promoted
+			"bar",
+			[]*Repository{testingRepo},
+			proto.Pin{Version: "1.0-1"},
+			[]republishing{
+				{testingRepo, filepath.Join(testingRepo.Dir, "4.bar-1.0-1-any.pkg.tar.xz")},
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		plan, err := getRepublishing(
+			testcase.Assigned, repos, testcase.Name, testcase.Pin,
+		)
+		test.NoError(err, testcase.Pin.Version)
+		test.Equal(testcase.Plan, plan, testcase.Pin.Version)
+	}
+
+	_, err = getRepublishing(
+		[]*Repository{testingRepo}, repos, "foo", proto.Pin{Version: "2.0-1"},
+	)
+	test.Error(err)
+
+	_, err = getRepublishing(nil, repos, "foo", proto.Pin{Version: "1.0-1"})
+	test.Error(err)
+}
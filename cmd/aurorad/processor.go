@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -8,44 +9,118 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
+	"github.com/kovetskiy/aurora/pkg/classify"
+	"github.com/kovetskiy/aurora/pkg/history"
+	"github.com/kovetskiy/aurora/pkg/pause"
 	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/schedule"
+	"github.com/kovetskiy/aurora/pkg/staging"
 	"github.com/reconquest/karma-go"
 	"github.com/reconquest/threadpool-go"
 )
 
+// defaultBuildTimeout is used if timeout.build in config is invalid.
+const defaultBuildTimeout = 30 * time.Minute
+
 type Processor struct {
-	repoDir   string
+	repos     []*Repository
 	bufferDir string
 	logsDir   string
 	pool      *threadpool.ThreadPool
 
-	storage *mgo.Collection
-	cloud   *Cloud
-	config  *Config
-	bus     *Bus
+	storage  *mgo.Collection
+	builds   *history.History
+	staging  *staging.Staging
+	pauses   *pause.Queue
+	classify *classify.Classifier
+	cloud    *Cloud
+	config   *Config
+	bus      *Bus
+	notifier proto.Notifier
+
+	registry *buildRegistry
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	// heartbeat is unix time in nanoseconds of the last queue iteration
+	heartbeat int64
+
+	// longestTimeout is the longest timeout of dispatched builds, queue
+	// iteration can wait for such build to finish
+	longestTimeout int64
+
+	// pause is the pause of the queue seen on the last check, nil if the
+	// queue is not paused
+	pause *proto.Pause
+
+	// stopped is the start of the pause for which the processor has
+	// recorded that it stopped dispatching builds
+	stopped time.Time
+
+	// upstreamChecks holds time of the last upstream check of packages
+	// which are rebuilt only on upstream change
+	upstreamChecks map[string]time.Time
 }
 
 func NewProcessor(
 	storage *mgo.Collection,
+	builds *history.History,
+	stages *staging.Staging,
+	pauses *pause.Queue,
+	classifier *classify.Classifier,
 	config *Config,
 	bus *Bus,
+	notifier proto.Notifier,
 ) *Processor {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &Processor{
-		storage: storage,
-		config:  config,
-		bus:     bus,
+		storage:  storage,
+		builds:   builds,
+		staging:  stages,
+		pauses:   pauses,
+		classify: classifier,
+		config:   config,
+		bus:      bus,
+		notifier: notifier,
+		registry: newBuildRegistry(),
+		ctx:      ctx,
+		cancel:   cancel,
+
+		upstreamChecks: map[string]time.Time{},
 	}
 }
 
 func (proc *Processor) Init() error {
-	err := proc.removeLock()
+	var err error
+
+	proc.repos, err = getRepositories(proc.config)
 	if err != nil {
-		return err
+		return karma.Format(
+			err,
+			"invalid repositories configuration",
+		)
+	}
+
+	err = migrateLegacyRepository(proc.repos)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to migrate repository to its own directory",
+		)
+	}
+
+	for _, repo := range proc.repos {
+		err = removeLock(repo)
+		if err != nil {
+			return err
+		}
 	}
 
 	err = cleanupQueue(proc.config.Instance, proc.storage)
@@ -56,7 +131,7 @@ func (proc *Processor) Init() error {
 		)
 	}
 
-	proc.repoDir, proc.bufferDir, proc.logsDir, err = prepareDirs(proc.config)
+	proc.bufferDir, proc.logsDir, err = prepareDirs(proc.config, proc.repos)
 	if err != nil {
 		return err
 	}
@@ -77,82 +152,344 @@ func (proc *Processor) Init() error {
 		)
 	}
 
-	proc.pool = spawnThreadpool(proc.config.Instance, proc.config.Threads)
+	proc.pool = spawnThreadpool(proc.config.Instance, getThreads(proc.config))
+
+	err = proc.pauses.Register(proc.config.Instance)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
 func (proc *Processor) Process() {
 	for {
-		pkg := proto.Package{}
+		proc.beat()
 
-		iterator := proc.storage.
-			Find(bson.M{}).
-			Sort("-priority").
-			Iter()
+		if proc.registry.isClosed() {
+			infof("queue processing has been stopped")
+			return
+		}
 
-		for iterator.Next(&pkg) {
-			var since time.Duration
-			var interval time.Duration
-			var canSkip bool
+		if proc.isPaused() {
+			proc.stop()
 
-			since = time.Since(pkg.Date)
+			time.Sleep(proc.config.Interval.Poll)
+			continue
+		}
+
+		pkgs := []proto.Package{}
 
-			// uh? looks ugly
-			switch pkg.Status {
-			case proto.BuildStatusProcessing.String():
-				interval = proc.config.Interval.Build.StatusProcessing
-				canSkip = true
+		err := proc.storage.Find(bson.M{}).All(&pkgs)
+		if err != nil {
+			errorh(err, "unable to load queue")
+		}
 
-			case proto.BuildStatusSuccess.String():
-				interval = proc.config.Interval.Build.StatusSuccess
-				canSkip = true
+		processor := describeProcessor(proc.config)
 
-			case proto.BuildStatusFailure.String():
-				interval = proc.config.Interval.Build.StatusFailure
-				canSkip = true
+		due := proc.getDuePackages(pkgs)
+
+		schedule.Order(due, processor, time.Now())
+
+		for _, pkg := range due {
+			if proc.registry.isClosed() {
+				break
 			}
 
-			if canSkip && since < interval {
+			limit := processor.Classes[pkg.Class]
+			if !proc.registry.reserve(pkg.Class, limit) {
 				tracef(
-					"skip package %s in status %s: "+
-						"time since last build %v is less than %v",
-					pkg.Name, pkg.Status, since, interval,
+					"skip package %s: %d builds of class %s are running",
+					pkg.Name, limit, pkg.Class,
 				)
 
 				continue
 			}
 
+			// the queue could be paused while previous push was blocked
+			if proc.isPaused() {
+				proc.registry.release(pkg.Class)
+				break
+			}
+
 			debugf("pushing %s to thread pool queue", pkg.Name)
 
-			proc.pool.Push(
-				&build{
-					bus:           proc.bus,
-					instance:      proc.config.Instance,
-					cloud:         proc.cloud,
-					storage:       proc.storage,
-					pkg:           pkg,
-					repoDir:       proc.repoDir,
-					bufferDir:     proc.bufferDir,
-					logsDir:       proc.logsDir,
-					configHistory: proc.config.History,
-				},
-			)
+			proc.pool.Push(proc.newBuild(pkg))
+
+			// pushing blocks while all threads are busy
+			proc.beat()
 		}
 
+		proc.promote()
+		proc.pin()
+
 		time.Sleep(proc.config.Interval.Poll)
 	}
 }
 
-func spawnThreadpool(instance string, size int) *threadpool.ThreadPool {
-	capacity := size
-	if capacity == 0 {
-		capacity = runtime.NumCPU()
+// isPaused checks whether the queue is paused and logs when it's paused or
+// resumed. Promotions and pins are not processed while paused as well since
+// they change repositories.
+func (proc *Processor) isPaused() bool {
+	current, err := proc.pauses.Get()
+	if err != nil {
+		errorh(err, "unable to check if the queue is paused")
+
+		// keep previous state until the database is back
+		return proc.pause != nil
+	}
+
+	paused := current != nil
+	if paused != (proc.pause != nil) {
+		if paused {
+			infof(
+				"queue has been paused by %s: %s, running builds: %s",
+				current.By, current.Reason,
+				strings.Join(proc.registry.list(), ", "),
+			)
+		} else {
+			infof("queue has been resumed")
+		}
+	}
+
+	proc.pause = current
+
+	return paused
+}
+
+// stop records that the processor has stopped for the pause once all
+// dispatched builds are finished, so aurora pause --drain can tell when no
+// build is going to start anymore.
+func (proc *Processor) stop() {
+	if proc.pause == nil || proc.stopped.Equal(proc.pause.Since) {
+		return
+	}
+
+	if !proc.registry.isIdle() {
+		return
+	}
+
+	err := proc.pauses.Stop(proc.config.Instance, *proc.pause)
+	if err != nil {
+		errorh(err, "unable to record that the queue is stopped")
+		return
+	}
+
+	proc.stopped = proc.pause.Since
+
+	infof("queue has been stopped, no builds are running")
+}
+
+// getDuePackages returns packages which should be built according to their
+// schedules.
+func (proc *Processor) getDuePackages(pkgs []proto.Package) []proto.Package {
+	due := []proto.Package{}
+
+	running := map[string]bool{}
+	for _, name := range proc.registry.list() {
+		running[name] = true
+	}
+
+	for _, pkg := range pkgs {
+		if proc.registry.isClosed() {
+			break
+		}
+
+		if pkg.Frozen {
+			tracef("skip package %s: frozen", pkg.Name)
+			continue
+		}
+
+		if pkg.Paused != nil {
+			tracef("skip package %s: paused by %s", pkg.Name, pkg.Paused.By)
+			continue
+		}
+
+		if running[pkg.Name] {
+			tracef("skip package %s: build is still running", pkg.Name)
+			continue
+		}
+
+		next := schedule.Next(pkg, getScheduleDefaults(proc.config))
+		if next.IsZero() {
+			if !proc.hasUpstreamChanged(&pkg) {
+				tracef("skip package %s: upstream has not changed", pkg.Name)
+				continue
+			}
+		} else if time.Now().Before(next) {
+			tracef(
+				"skip package %s in status %s: next build is planned at %s",
+				pkg.Name, pkg.Status, next.Format(time.RFC3339),
+			)
+
+			continue
+		}
+
+		due = append(due, pkg)
+	}
+
+	return due
+}
+
+func (proc *Processor) newBuild(pkg proto.Package) *build {
+	repos := pkg.Repos
+	if len(repos) == 0 {
+		repos = getDefaultRepositories(proc.config)
+	}
+
+	timeout := getBuildTimeout(proc.config, pkg)
+	if int64(timeout) > atomic.LoadInt64(&proc.longestTimeout) {
+		atomic.StoreInt64(&proc.longestTimeout, int64(timeout))
+	}
+
+	return &build{
+		ctx:        proc.ctx,
+		registry:   proc.registry,
+		bus:        proc.bus,
+		notifier:   proc.notifier,
+		builds:     proc.builds,
+		staging:    proc.staging,
+		instance:   proc.config.Instance,
+		cloud:      proc.cloud,
+		storage:    proc.storage,
+		pauses:     proc.pauses,
+		pkg:        pkg,
+		repos:      findRepositories(proc.repos, repos),
+		bufferDir:  proc.bufferDir,
+		logsDir:    proc.logsDir,
+		classifier: proc.classify,
+		schedule:   getScheduleDefaults(proc.config),
+		quarantine: proc.config.Scheduling.Quarantine,
+		timeout:    timeout,
+	}
+}
+
+// getBuildTimeout returns timeout of the package or the global one.
+func getBuildTimeout(config *Config, pkg proto.Package) time.Duration {
+	if pkg.Timeout > 0 {
+		return pkg.Timeout
+	}
+
+	timeout, err := time.ParseDuration(config.Timeout.Build)
+	if err != nil {
+		return defaultBuildTimeout
+	}
+
+	return timeout
+}
+
+func getScheduleDefaults(config *Config) schedule.Defaults {
+	return schedule.Defaults{
+		Processing: config.Interval.Build.StatusProcessing,
+		Success:    config.Interval.Build.StatusSuccess,
+		Failure:    config.Interval.Build.StatusFailure,
+		Backoff:    config.Scheduling.Backoff,
+		Timeout:    getBuildTimeout(config, proto.Package{}),
+	}
+}
+
+// describeProcessor returns settings of the processor which are used for
+// ordering the queue, the web server assumes every instance has the same
+// settings when estimating the queue.
+func describeProcessor(config *Config) schedule.Processor {
+	classes := map[string]int{}
+	for name, class := range config.Scheduling.Classes {
+		classes[name] = class.Limit
+	}
+
+	return schedule.Processor{
+		Instance: config.Instance,
+		Threads:  getThreads(config),
+		Defaults: getScheduleDefaults(config),
+		Aging:    config.Scheduling.Aging,
+		Classes:  classes,
+	}
+}
+
+// Shutdown stops taking packages from the queue and waits for running
+// builds to finish, builds which are still running after grace period are
+// cancelled.
+func (proc *Processor) Shutdown(grace time.Duration) {
+	proc.registry.close()
+
+	running := proc.registry.list()
+	if len(running) > 0 {
+		infof(
+			"waiting %s for %d builds to finish: %s",
+			grace, len(running), strings.Join(running, ", "),
+		)
+	}
+
+	deadline := time.Now().Add(grace)
+	for len(running) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Second)
+
+		running = proc.registry.list()
+	}
+
+	if len(running) > 0 {
+		warningf(
+			"grace period is over, cancelling %d builds: %s",
+			len(running), strings.Join(running, ", "),
+		)
+
+		proc.cancel()
+
+		// cancelled builds still need to destroy containers and save
+		// their status
+		for len(proc.registry.list()) > 0 {
+			time.Sleep(time.Second)
+		}
+	}
+
+	infof("all builds have been finished")
+
+	err := proc.pauses.Unregister(proc.config.Instance)
+	if err != nil {
+		errorh(err, "unable to unregister processor")
+	}
+}
+
+func (proc *Processor) beat() {
+	atomic.StoreInt64(&proc.heartbeat, time.Now().UnixNano())
+}
+
+// CheckAlive fails if the queue is not processed for too long, that is
+// longer than a build can take plus several polls.
+func (proc *Processor) CheckAlive() error {
+	if proc.registry.isClosed() {
+		return nil
+	}
+
+	timeout := getBuildTimeout(proc.config, proto.Package{})
+	if longest := time.Duration(atomic.LoadInt64(&proc.longestTimeout)); longest > timeout {
+		timeout = longest
+	}
+
+	threshold := timeout + proc.config.Interval.Poll*10
+
+	since := time.Since(time.Unix(0, atomic.LoadInt64(&proc.heartbeat)))
+	if since > threshold {
+		return fmt.Errorf("queue is not processed for %s", since)
+	}
+
+	return nil
+}
+
+func getThreads(config *Config) int {
+	if config.Threads == 0 {
+		return runtime.NumCPU()
 	}
 
+	return config.Threads
+}
+
+func spawnThreadpool(instance string, capacity int) *threadpool.ThreadPool {
 	pool := threadpool.New()
 	pool.Spawn(capacity)
 
+	metricThreads.Set(float64(capacity))
+
 	infof(
 		"thread pool with %d threads has been spawned as instance %q",
 		capacity, instance,
@@ -163,41 +500,38 @@ func spawnThreadpool(instance string, size int) *threadpool.ThreadPool {
 
 func prepareDirs(
 	config *Config,
-) (repoDir, bufferDir, logsDir string, err error) {
-	repoDir, err = filepath.Abs(config.RepoDir)
-	if err != nil {
-		return "", "", "", err
-	}
-
+	repos []*Repository,
+) (bufferDir, logsDir string, err error) {
 	bufferDir, err = filepath.Abs(
 		filepath.Join(config.BufferDir, config.Instance),
 	)
 	if err != nil {
-		return "", "", "", err
+		return "", "", err
 	}
 
 	err = os.RemoveAll(bufferDir)
 	if err != nil {
-		return "", "", "", karma.Format(
+		return "", "", karma.Format(
 			err,
 			"unable to remove buffer directory",
 		)
 	}
 
-	for _, dir := range []string{
-		repoDir,
-		bufferDir,
-		config.LogsDir,
-	} {
+	dirs := []string{bufferDir, config.LogsDir}
+	for _, repo := range repos {
+		dirs = append(dirs, repo.Dir)
+	}
+
+	for _, dir := range dirs {
 		err := os.MkdirAll(dir, 0755)
 		if err != nil {
-			return "", "", "", karma.Format(
+			return "", "", karma.Format(
 				err, "can't mkdir %s", dir,
 			)
 		}
 	}
 
-	return repoDir, bufferDir, config.LogsDir, nil
+	return bufferDir, config.LogsDir, nil
 }
 
 func cleanupQueue(instance string, storage *mgo.Collection) error {
@@ -231,8 +565,8 @@ func cleanupQueue(instance string, storage *mgo.Collection) error {
 	return nil
 }
 
-func (proc *Processor) removeLock() error {
-	path := filepath.Join(proc.config.RepoDir, packagesDatabaseFile+".lck")
+func removeLock(repo *Repository) error {
+	path := repo.Database() + ".lck"
 
 	infof("ensuring database lock file does not exist: %s", path)
 
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/kovetskiy/aurora/pkg/archive"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/reconquest/karma-go"
+)
+
+// promote promotes staged packages which are requested to be promoted by
+// hand or which soak period is over without reported problems.
+func (proc *Processor) promote() {
+	stages, err := proc.staging.FindPending()
+	if err != nil {
+		errorh(err, "unable to find pending promotions")
+		return
+	}
+
+	for _, stage := range stages {
+		if proc.registry.isClosed() {
+			return
+		}
+
+		from := findRepositories(proc.repos, []string{stage.Repo})
+		to := findRepositories(proc.repos, []string{stage.Target})
+		if from == nil || to == nil {
+			tracef(
+				"skip promotion of %s from %s to %s: repository is not configured",
+				stage.Package, stage.Repo, stage.Target,
+			)
+
+			continue
+		}
+
+		if !stage.Requested && !isSoaked(stage, from[0].Soak) {
+			continue
+		}
+
+		var pkg proto.Package
+
+		err := proc.storage.Find(bson.M{"name": stage.Package}).One(&pkg)
+		if err == mgo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			errorh(err, "unable to find package %s", stage.Package)
+			continue
+		}
+
+		// frozen package keeps its published build, pinned one could be
+		// rolled back from the staged version
+		if pkg.Frozen || pkg.Pin != nil {
+			tracef(
+				"skip promotion of %s to %s: package is frozen",
+				stage.Package, stage.Target,
+			)
+
+			continue
+		}
+
+		version := stage.Version
+		if stage.RequestedVersion != "" {
+			version = stage.RequestedVersion
+		}
+
+		promotion := proc.newBuild(pkg)
+		promotion.init()
+
+		err = promotion.promote(from[0], to[0], version)
+		if err != nil {
+			promotion.log.Error(err)
+
+			err = proc.staging.Fail(stage, version, err)
+		} else {
+			err = proc.staging.Promoted(stage, version)
+		}
+		if err != nil {
+			promotion.log.Error(err)
+		}
+	}
+}
+
+// isSoaked returns true if the staged version can be promoted
+// automatically.
+func isSoaked(stage proto.Stage, soak time.Duration) bool {
+	if soak == 0 || len(stage.Problems) > 0 {
+		return false
+	}
+
+	return time.Since(stage.Published) >= soak
+}
+
+// promote adds archive of specified version from the testing repository to
+// the stable one.
+func (build *build) promote(from, to *Repository, version string) error {
+	archive, err := findArchive(from, build.pkg.Name, version)
+	if err != nil {
+		return err
+	}
+
+	build.log.Infof("promoting %s from %s to %s", version, from, to)
+
+	err = build.addToRepository(to, archive)
+	if err != nil {
+		return err
+	}
+
+	err = build.cleanup(to)
+	if err != nil {
+		build.log.Error(err)
+	}
+
+	event := proto.NewEvent(proto.EventPackagePromoted, build.pkg)
+	event.Version = version
+	event.Repo = to.Name
+
+	build.publishEvent(event)
+
+	return nil
+}
+
+// hasArchive returns true if the repository holds any archive of the
+// package.
+func hasArchive(repo *Repository, name string) (bool, error) {
+	globbed, err := filepath.Glob(
+		filepath.Join(repo.Dir, fmt.Sprintf("*.%s-*.pkg.*", name)),
+	)
+	if err != nil {
+		return false, karma.Format(
+			err,
+			"unable to glob for packages",
+		)
+	}
+
+	for _, path := range globbed {
+		parsed, ok := archive.Parse(filepath.Base(path))
+		if ok && parsed.Name == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// findArchive returns path to the newest archive of specified version of
+// the package in the repository.
+func findArchive(repo *Repository, name, version string) (string, error) {
+	globbed, err := filepath.Glob(
+		filepath.Join(repo.Dir, fmt.Sprintf("*.%s-%s-*.pkg.*", name, version)),
+	)
+	if err != nil {
+		return "", karma.Format(
+			err,
+			"unable to glob for packages",
+		)
+	}
+
+	var newest, newestTime string
+	for _, path := range globbed {
+		parsed, ok := archive.Parse(filepath.Base(path))
+		if !ok || parsed.Name != name || parsed.Version != version {
+			continue
+		}
+
+		if parsed.Time > newestTime {
+			newest = path
+			newestTime = parsed.Time
+		}
+	}
+
+	if newest == "" {
+		return "", fmt.Errorf(
+			"archive of %s %s not found in %s repository", name, version, repo,
+		)
+	}
+
+	return newest, nil
+}
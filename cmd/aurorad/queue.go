@@ -2,18 +2,71 @@ package main
 
 import (
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/coreos/go-systemd/daemon"
 	"github.com/globalsign/mgo"
+	"github.com/kovetskiy/aurora/pkg/classify"
+	"github.com/kovetskiy/aurora/pkg/history"
+	"github.com/kovetskiy/aurora/pkg/mail"
+	"github.com/kovetskiy/aurora/pkg/pause"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/staging"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/reconquest/karma-go"
 )
 
-func processQueue(storage *mgo.Collection, config *Config) error {
-	bus := NewBus()
+func processQueue(
+	storage *mgo.Collection,
+	builds *history.History,
+	stages *staging.Staging,
+	pauses *pause.Queue,
+	notifier proto.Notifier,
+	config *Config,
+) error {
+	bus, err := NewBus(config.Bus)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to initialize bus",
+		)
+	}
+
+	classifier, err := classify.NewClassifier(config.Classify.Rules)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to initialize failure classifier",
+		)
+	}
+
+	if config.Mail.SMTP.Address != "" {
+		digestAt, err := mail.ParseDigestTime(config.Mail.DigestAt)
+		if err != nil {
+			return err
+		}
 
-	processor := NewProcessor(storage, config, bus)
+		mailer := mail.NewMailer(
+			config.Mail.SMTP,
+			config.Mail.Users,
+			config.LogsDir,
+			config.Mail.LogTail,
+			logger,
+		)
+
+		go mailer.RunDigest(digestAt)
+
+		notifier = proto.Notifiers{notifier, mailer}
+	}
+
+	processor := NewProcessor(
+		storage, builds, stages, pauses, classifier, config, bus, notifier,
+	)
 	busServer := NewBusServer(bus)
 
-	err := processor.Init()
+	err = processor.Init()
 	if err != nil {
 		return karma.Format(
 			err,
@@ -23,16 +76,60 @@ func processQueue(storage *mgo.Collection, config *Config) error {
 
 	go processor.Process()
 
-	infof("starting bus server at %s", config.Bus.Listen)
+	registerCommonMetrics(storage, config)
+	registerBusMetrics(bus)
 
-	err = http.ListenAndServe(config.Bus.Listen, busServer)
-	if err != nil {
+	health := NewHealth()
+	health.AddLive("queue", processor.CheckAlive)
+	health.AddReady("database", checkDatabase(storage))
+	health.AddReady("docker", processor.cloud.Ping)
+	addRepositoryChecks(health, processor.repos)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", health.ServeLive)
+	mux.HandleFunc("/readyz", health.ServeReady)
+	mux.Handle("/", busServer)
+
+	go health.NotifySystemd()
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	server := &http.Server{Addr: config.Bus.Listen, Handler: mux}
+
+	serveErrors := make(chan error, 1)
+	go func() {
+		infof("starting bus server at %s", config.Bus.Listen)
+
+		serveErrors <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErrors:
 		return karma.Format(
 			err,
 			"unable to listen and serve bus server at %s",
 			config.Bus.Listen,
 		)
+
+	case received := <-signals:
+		infof("received %s, shutting down", received)
 	}
 
-	return nil
+	go func() {
+		received := <-signals
+		fatalf("received %s again, exiting without waiting for builds", received)
+	}()
+
+	_, err = daemon.SdNotify(false, daemon.SdNotifyStopping)
+	if err != nil {
+		errorh(err, "unable to notify systemd about stopping")
+	}
+
+	processor.Shutdown(config.Shutdown.Grace)
+
+	// bus server is still serving while builds are draining, so logs of
+	// running builds can be followed until the end
+	return server.Close()
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// buildRegistry keeps track of builds running in the thread pool, so
+// shutdown can wait for them and no new builds start once it's closed.
+type buildRegistry struct {
+	mutex   *sync.Mutex
+	running map[string]struct{}
+	closed  bool
+
+	// classes holds number of dispatched builds of every resource class
+	classes map[string]int
+}
+
+func newBuildRegistry() *buildRegistry {
+	return &buildRegistry{
+		mutex:   &sync.Mutex{},
+		running: map[string]struct{}{},
+		classes: map[string]int{},
+	}
+}
+
+// start registers build of the package, returns false if registry is
+// closed and the build should not be started.
+func (registry *buildRegistry) start(name string) bool {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if registry.closed {
+		return false
+	}
+
+	registry.running[name] = struct{}{}
+
+	return true
+}
+
+func (registry *buildRegistry) finish(name string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	delete(registry.running, name)
+}
+
+// close prevents new builds from starting.
+func (registry *buildRegistry) close() {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.closed = true
+}
+
+func (registry *buildRegistry) isClosed() bool {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	return registry.closed
+}
+
+// list returns names of packages being built now.
+func (registry *buildRegistry) list() []string {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	names := []string{}
+	for name := range registry.running {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// isIdle returns true if no builds are dispatched or running.
+func (registry *buildRegistry) isIdle() bool {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	return len(registry.running) == 0 && len(registry.classes) == 0
+}
+
+// reserve takes a slot of the resource class for a build which is going to
+// be dispatched, returns false if limit of the class is reached. Zero limit
+// means no limit.
+func (registry *buildRegistry) reserve(class string, limit int) bool {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if limit > 0 && registry.classes[class] >= limit {
+		return false
+	}
+
+	registry.classes[class]++
+
+	return true
+}
+
+// release frees the slot of the resource class taken by reserve.
+func (registry *buildRegistry) release(class string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.classes[class]--
+	if registry.classes[class] <= 0 {
+		delete(registry.classes, class)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/rpc"
+	"github.com/reconquest/karma-go"
+)
+
+// defaultRepository is used when config has no repos section, it's also
+// the name of the database which was stored right in repo_dir before
+// several repositories were supported.
+const defaultRepository = "aurora"
+
+// reservedRepositoryNames can't be used as repository names because the
+// repositories are served at /<name>/ by the web server.
+var reservedRepositoryNames = []string{
+	"api", "bus", "healthz", "metrics", "readyz", "rpc", "ui",
+}
+
+// Repository is a pacman repository stored in repo_dir/<name>/ with
+// database <name>.db.tar, so it can be used with
+// Server = https://aurora.example.com/$repo in pacman.conf.
+type Repository struct {
+	Name    string
+	Dir     string
+	Key     string
+	History ConfigHistory
+
+	// PromoteTo is set for testing repositories, packages are promoted
+	// from them by hand or after Soak period without reported problems.
+	PromoteTo string
+	Soak      time.Duration
+}
+
+func (repo *Repository) String() string {
+	return repo.Name
+}
+
+func (repo *Repository) Database() string {
+	return filepath.Join(repo.Dir, repo.Name+".db.tar")
+}
+
+// getRepositories returns repositories described in config sorted by name.
+func getRepositories(config *Config) ([]*Repository, error) {
+	root, err := filepath.Abs(config.RepoDir)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := config.Repos
+	if len(settings) == 0 {
+		settings = map[string]ConfigRepository{defaultRepository: {}}
+	}
+
+	repos := []*Repository{}
+	for name, repo := range settings {
+		if !proto.IsValidRepositoryName(name) {
+			return nil, fmt.Errorf("invalid repository name: %q", name)
+		}
+
+		for _, reserved := range reservedRepositoryNames {
+			if name == reserved {
+				return nil, fmt.Errorf("repository name is reserved: %q", name)
+			}
+		}
+
+		history := config.History
+		if repo.History != nil {
+			history = *repo.History
+		}
+
+		repos = append(repos, &Repository{
+			Name:      name,
+			Dir:       filepath.Join(root, name),
+			Key:       repo.Key,
+			History:   history,
+			PromoteTo: repo.PromoteTo,
+			Soak:      repo.Soak,
+		})
+	}
+
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].Name < repos[j].Name
+	})
+
+	for _, repo := range repos {
+		if repo.PromoteTo == "" {
+			continue
+		}
+
+		if repo.PromoteTo == repo.Name ||
+			findRepositories(repos, []string{repo.PromoteTo}) == nil {
+			return nil, fmt.Errorf(
+				"repository %s is promoted to unknown repository: %q",
+				repo.Name, repo.PromoteTo,
+			)
+		}
+	}
+
+	for _, name := range getDefaultRepositories(config) {
+		if findRepositories(repos, []string{name}) == nil {
+			return nil, fmt.Errorf("default repository is not configured: %q", name)
+		}
+	}
+
+	return repos, nil
+}
+
+func getDefaultRepositories(config *Config) []string {
+	if len(config.DefaultRepos) > 0 {
+		return config.DefaultRepos
+	}
+
+	return []string{defaultRepository}
+}
+
+// describeRepositories returns repositories in form suitable for validation
+// of packages added through RPC.
+func describeRepositories(repos []*Repository, config *Config) rpc.Repositories {
+	described := rpc.Repositories{
+		Default: getDefaultRepositories(config),
+	}
+
+	for _, repo := range repos {
+		described.Available = append(described.Available, repo.Name)
+	}
+
+	return described
+}
+
+// findRepositories returns repositories with specified names, unknown names
+// are skipped.
+func findRepositories(repos []*Repository, names []string) []*Repository {
+	var found []*Repository
+	for _, name := range names {
+		for _, repo := range repos {
+			if repo.Name == name {
+				found = append(found, repo)
+				break
+			}
+		}
+	}
+
+	return found
+}
+
+// migrateLegacyRepository moves packages and database of aurora repository
+// from repo_dir to repo_dir/aurora/, where it's stored now.
+func migrateLegacyRepository(repos []*Repository) error {
+	found := findRepositories(repos, []string{defaultRepository})
+	if found == nil {
+		return nil
+	}
+
+	repo := found[0]
+	root := filepath.Dir(repo.Dir)
+
+	_, err := os.Stat(filepath.Join(root, filepath.Base(repo.Database())))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	_, err = os.Stat(repo.Database())
+	if err == nil {
+		return nil
+	}
+
+	infof("moving %s repository from %s to %s", repo.Name, root, repo.Dir)
+
+	err = os.MkdirAll(repo.Dir, 0755)
+	if err != nil {
+		return karma.Format(err, "can't mkdir %s", repo.Dir)
+	}
+
+	var files []string
+	for _, pattern := range []string{
+		"*.pkg.*",
+		repo.Name + ".db*",
+		repo.Name + ".files*",
+	} {
+		globbed, err := filepath.Glob(filepath.Join(root, pattern))
+		if err != nil {
+			return karma.Format(err, "unable to glob for %s", pattern)
+		}
+
+		files = append(files, globbed...)
+	}
+
+	for _, path := range files {
+		err := os.Rename(path, filepath.Join(repo.Dir, filepath.Base(path)))
+		if err != nil {
+			return karma.Format(err, "unable to move %s", path)
+		}
+	}
+
+	return nil
+}
+
+// linkFile hard links source to target or copies it if they are on
+// different filesystems. Archive names contain time of the build, so
+// existing target is the same archive.
+func linkFile(source, target string) error {
+	err := os.Link(source, target)
+	if err == nil || os.IsExist(err) {
+		return nil
+	}
+
+	input, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+
+	defer input.Close()
+
+	output, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(output, input)
+	if err != nil {
+		output.Close()
+		return err
+	}
+
+	return output.Close()
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/go-chi/chi"
+	"github.com/kovetskiy/aurora/pkg/history"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/rpc"
+	"github.com/kovetskiy/aurora/pkg/schedule"
+	"github.com/kovetskiy/aurora/pkg/signature"
+	"github.com/kovetskiy/aurora/pkg/vercmp"
+)
+
+const (
+	uiPrefix = "/ui"
+
+	// uiSessionCookie holds token issued by AuthService.IssueToken
+	uiSessionCookie = "aurora_session"
+)
+
+// UI is a read-only web interface, it follows the same rules as RPC: signed
+// users (by signature header or session cookie) see everything, anonymous
+// users see public packages if corresponding methods are allowed for
+// anonymous callers.
+type UI struct {
+	collection *mgo.Collection
+	builds     *history.History
+	auth       *rpc.AuthService
+	tokens     *rpc.Tokens
+	logsDir    string
+	templates  *template.Template
+}
+
+func NewUI(
+	collection *mgo.Collection,
+	builds *history.History,
+	auth *rpc.AuthService,
+	tokens *rpc.Tokens,
+	logsDir string,
+) *UI {
+	templates := template.Must(
+		template.New("").Funcs(template.FuncMap{
+			"time": func(value time.Time) string {
+				if value.IsZero() {
+					return "-"
+				}
+
+				return value.Format("2006-01-02 15:04:05")
+			},
+			"duration": func(value time.Duration) string {
+				return value.Round(time.Second).String()
+			},
+			"schedule": schedule.Format,
+		}).Parse(uiTemplates),
+	)
+
+	return &UI{
+		collection: collection,
+		builds:     builds,
+		auth:       auth,
+		tokens:     tokens,
+		logsDir:    logsDir,
+		templates:  templates,
+	}
+}
+
+func (ui *UI) Route(router chi.Router) {
+	router.Get("/", ui.serveIndex)
+	router.Get("/login", ui.serveLogin)
+	router.Get("/logout", ui.serveLogout)
+	router.Get("/packages/{name}", ui.servePackage)
+	router.Get("/packages/{name}/logs", ui.serveLogs)
+	router.Get("/packages/{name}/live", ui.serveLive)
+	router.Get("/packages/{name}/status.svg", ui.serveStatusBadge)
+	router.Get("/packages/{name}/version.svg", ui.serveVersionBadge)
+	router.Get("/feed.atom", ui.serveFeed)
+}
+
+// authorize returns signer of the request, nil signer means that the caller
+// is anonymous and allowed to call specified method. Session token can be
+// also passed in token query parameter for clients which can't keep
+// cookies, e.g. feed readers.
+func (ui *UI) authorize(
+	request *http.Request,
+	method string,
+) (*signature.Signer, error) {
+	signer := ui.auth.VerifyRequest(request)
+	if signer != nil {
+		return signer, nil
+	}
+
+	cookie, err := request.Cookie(uiSessionCookie)
+	if err == nil {
+		signer = ui.auth.VerifySession(cookie.Value)
+		if signer != nil {
+			return signer, nil
+		}
+	}
+
+	token := request.URL.Query().Get("token")
+	if token != "" {
+		signer = ui.auth.VerifySession(token)
+		if signer != nil {
+			return signer, nil
+		}
+	}
+
+	if ui.auth.AllowsAnonymous(method) {
+		return nil, nil
+	}
+
+	return nil, rpc.ErrorUnauthorized
+}
+
+type uiPage struct {
+	Title  string
+	Signer *signature.Signer
+	Data   interface{}
+}
+
+func (ui *UI) render(
+	response http.ResponseWriter,
+	status int,
+	name string,
+	page uiPage,
+) {
+	response.Header().Set("Content-Type", "text/html; charset=utf-8")
+	response.WriteHeader(status)
+
+	err := ui.templates.ExecuteTemplate(response, name, page)
+	if err != nil {
+		errorh(err, "unable to render template %s", name)
+	}
+}
+
+func (ui *UI) renderError(response http.ResponseWriter, status int, err error) {
+	ui.render(response, status, "error", uiPage{
+		Title: http.StatusText(status),
+		Data: map[string]interface{}{
+			"Error":        err.Error(),
+			"Unauthorized": status == http.StatusUnauthorized,
+		},
+	})
+}
+
+type uiInstance struct {
+	Name     string
+	Building []string
+}
+
+func (ui *UI) serveIndex(response http.ResponseWriter, request *http.Request) {
+	signer, err := ui.authorize(request, "PackageService.ListPackages")
+	if err != nil {
+		ui.renderError(response, http.StatusUnauthorized, err)
+		return
+	}
+
+	packages := []proto.Package{}
+
+	err = ui.collection.Find(rpc.VisibleTo(signer, bson.M{})).
+		Sort("name").
+		All(&packages)
+	if err != nil {
+		errorh(err, "unable to find packages")
+
+		ui.renderError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	statuses := map[string]int{}
+	instances := map[string]*uiInstance{}
+	for _, pkg := range packages {
+		statuses[pkg.Status]++
+
+		if pkg.Instance == "" {
+			continue
+		}
+
+		instance, ok := instances[pkg.Instance]
+		if !ok {
+			instance = &uiInstance{Name: pkg.Instance}
+			instances[pkg.Instance] = instance
+		}
+
+		if pkg.Status == proto.BuildStatusProcessing.String() {
+			instance.Building = append(instance.Building, pkg.Name)
+		}
+	}
+
+	overview := []*uiInstance{}
+	for _, instance := range instances {
+		overview = append(overview, instance)
+	}
+
+	sort.Slice(overview, func(i, j int) bool {
+		return overview[i].Name < overview[j].Name
+	})
+
+	ui.render(response, http.StatusOK, "index", uiPage{
+		Title:  "packages",
+		Signer: signer,
+		Data: map[string]interface{}{
+			"Packages":  packages,
+			"Statuses":  statuses,
+			"Instances": overview,
+		},
+	})
+}
+
+func (ui *UI) servePackage(response http.ResponseWriter, request *http.Request) {
+	signer, err := ui.authorize(request, "PackageService.GetPackage")
+	if err != nil {
+		ui.renderError(response, http.StatusUnauthorized, err)
+		return
+	}
+
+	pkg, ok := ui.getPackage(response, request, signer)
+	if !ok {
+		return
+	}
+
+	builds, err := ui.builds.Find(pkg.Name, 0)
+	if err != nil {
+		errorh(err, "unable to find builds of %s", pkg.Name)
+
+		ui.renderError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	ui.render(response, http.StatusOK, "package", uiPage{
+		Title:  pkg.Name,
+		Signer: signer,
+		Data: map[string]interface{}{
+			"Package":  pkg,
+			"Builds":   builds,
+			"Versions": getBuiltVersions(builds),
+			"Badges": []string{
+				getBadgeMarkdown(getBaseURL(request), pkg.Name, "status"),
+				getBadgeMarkdown(getBaseURL(request), pkg.Name, "version"),
+			},
+		},
+	})
+}
+
+// getBuiltVersions returns versions of successful builds from the newest to
+// the oldest one.
+func getBuiltVersions(builds []proto.Build) []string {
+	versions := []string{}
+	for _, build := range builds {
+		if build.Status != proto.BuildStatusSuccess.String() {
+			continue
+		}
+
+		found := false
+		for _, version := range versions {
+			if vercmp.Compare(version, build.Version) == 0 {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			versions = append(versions, build.Version)
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return vercmp.Compare(versions[i], versions[j]) > 0
+	})
+
+	return versions
+}
+
+func (ui *UI) serveLogs(response http.ResponseWriter, request *http.Request) {
+	signer, err := ui.authorize(request, "PackageService.GetLogs")
+	if err != nil {
+		ui.renderError(response, http.StatusUnauthorized, err)
+		return
+	}
+
+	pkg, ok := ui.getPackage(response, request, signer)
+	if !ok {
+		return
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(ui.logsDir, pkg.Name))
+	if err != nil && !os.IsNotExist(err) {
+		errorh(err, "unable to read logs of %s", pkg.Name)
+
+		ui.renderError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	response.Write(contents)
+}
+
+func (ui *UI) serveLive(response http.ResponseWriter, request *http.Request) {
+	signer, err := ui.authorize(request, "PackageService.GetBus")
+	if err != nil {
+		ui.renderError(response, http.StatusUnauthorized, err)
+		return
+	}
+
+	pkg, ok := ui.getPackage(response, request, signer)
+	if !ok {
+		return
+	}
+
+	ui.render(response, http.StatusOK, "live", uiPage{
+		Title:  pkg.Name + " live",
+		Signer: signer,
+		Data: map[string]interface{}{
+			"Package": pkg,
+			"Token": ui.tokens.Issue(
+				rpc.BusTokenSubject(pkg.Name, signer == nil),
+				rpc.BusTokenTTL,
+			),
+		},
+	})
+}
+
+func (ui *UI) serveLogin(response http.ResponseWriter, request *http.Request) {
+	token := request.URL.Query().Get("token")
+
+	if ui.auth.VerifySession(token) == nil {
+		ui.renderError(response, http.StatusUnauthorized, rpc.ErrorInvalidToken)
+		return
+	}
+
+	http.SetCookie(response, &http.Cookie{
+		Name:     uiSessionCookie,
+		Value:    token,
+		Path:     uiPrefix,
+		Expires:  time.Now().Add(rpc.SessionTokenTTL),
+		HttpOnly: true,
+		Secure:   request.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(response, request, uiPrefix+"/", http.StatusFound)
+}
+
+func (ui *UI) serveLogout(response http.ResponseWriter, request *http.Request) {
+	http.SetCookie(response, &http.Cookie{
+		Name:   uiSessionCookie,
+		Path:   uiPrefix,
+		MaxAge: -1,
+	})
+
+	http.Redirect(response, request, uiPrefix+"/", http.StatusFound)
+}
+
+// getPackage finds the package specified in URL and renders error page if
+// it can't be found.
+func (ui *UI) getPackage(
+	response http.ResponseWriter,
+	request *http.Request,
+	signer *signature.Signer,
+) (*proto.Package, bool) {
+	pkg, err := ui.findPackage(signer, chi.URLParam(request, "name"))
+	switch err {
+	case nil:
+		return pkg, true
+
+	case rpc.ErrorInvalidPackageName:
+		ui.renderError(response, http.StatusBadRequest, err)
+
+	case rpc.ErrorNoSuchPackage:
+		ui.renderError(response, http.StatusNotFound, err)
+
+	default:
+		errorh(err, "unable to find package")
+
+		ui.renderError(response, http.StatusInternalServerError, err)
+	}
+
+	return nil, false
+}
+
+func (ui *UI) findPackage(
+	signer *signature.Signer,
+	name string,
+) (*proto.Package, error) {
+	if !proto.IsValidPackageName(name) {
+		return nil, rpc.ErrorInvalidPackageName
+	}
+
+	var pkg proto.Package
+
+	err := ui.collection.Find(
+		rpc.VisibleTo(signer, bson.M{"name": name}),
+	).One(&pkg)
+	if err == mgo.ErrNotFound {
+		return nil, rpc.ErrorNoSuchPackage
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &pkg, nil
+}
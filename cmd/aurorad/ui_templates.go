@@ -0,0 +1,183 @@
+package main
+
+const uiTemplates = `
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>aurora: {{.Title}}</title>
+<link rel="alternate" type="application/atom+xml" title="aurora updates" href="/ui/feed.atom">
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+th, td { padding: 0.3em 1em 0.3em 0; text-align: left; }
+th { border-bottom: 1px solid #ccc; }
+pre { background: #f6f6f6; padding: 1em; overflow-x: auto; }
+.status-success { color: #2a7d2a; }
+.status-failure { color: #c62828; }
+.status-processing { color: #1565c0; }
+.status-cancelled, .status-unknown { color: #777; }
+nav { margin-bottom: 1.5em; }
+nav span { float: right; }
+</style>
+</head>
+<body>
+<nav>
+<a href="/ui/">packages</a>
+<span>{{if .Signer}}{{.Signer.Name}} | <a href="/ui/logout">logout</a>{{else}}anonymous{{end}}</span>
+</nav>
+{{end}}
+
+{{define "footer"}}
+</body>
+</html>
+{{end}}
+
+{{define "status"}}<span class="status-{{.}}">{{.}}</span>{{end}}
+
+{{define "error"}}{{template "header" .}}
+<h1>{{.Title}}</h1>
+<p>{{.Data.Error}}</p>
+{{if .Data.Unauthorized}}
+<p>Run <code>aurora login</code> and open printed link to sign in.</p>
+{{end}}
+{{template "footer" .}}{{end}}
+
+{{define "index"}}{{template "header" .}}
+<h2>Instances</h2>
+<table>
+<tr><th>instance</th><th>building</th></tr>
+{{range .Data.Instances}}
+<tr>
+<td>{{.Name}}</td>
+<td>{{range $index, $name := .Building}}{{if $index}}, {{end}}<a href="/ui/packages/{{$name}}/live">{{$name}}</a>{{else}}idle{{end}}</td>
+</tr>
+{{end}}
+</table>
+
+<h2>Queue</h2>
+<table>
+<tr><th>status</th><th>packages</th></tr>
+{{range $status, $count := .Data.Statuses}}
+<tr><td>{{template "status" $status}}</td><td>{{$count}}</td></tr>
+{{end}}
+</table>
+
+<h2>Packages</h2>
+<table>
+<tr><th>name</th><th>status</th><th>version</th><th>last build</th><th>instance</th><th>owner</th></tr>
+{{range .Data.Packages}}
+<tr>
+<td><a href="/ui/packages/{{.Name}}">{{.Name}}</a></td>
+<td>{{template "status" .Status}}</td>
+<td>{{.Version}}</td>
+<td>{{time .Date}}</td>
+<td>{{.Instance}}</td>
+<td>{{.Owner}}</td>
+</tr>
+{{end}}
+</table>
+{{template "footer" .}}{{end}}
+
+{{define "package"}}{{template "header" .}}
+{{with .Data.Package}}
+<h1>{{.Name}}</h1>
+<table>
+<tr><td>status</td><td>{{template "status" .Status}}</td></tr>
+<tr><td>version</td><td>{{.Version}}{{if .Pin}} (pinned){{else if .Frozen}} (frozen){{end}}</td></tr>
+<tr><td>last build</td><td>{{time .Date}}</td></tr>
+<tr><td>schedule</td><td>{{with schedule .Schedule}}{{.}}{{else}}default{{end}}</td></tr>
+<tr><td>next build</td><td>{{if .Frozen}}frozen{{else if .Paused}}paused by {{.Paused.By}}{{else if .Quarantined}}quarantined after {{.Failures}} failures, on upstream change{{else}}{{time .Next}}{{end}}</td></tr>
+<tr><td>instance</td><td>{{.Instance}}</td></tr>
+<tr><td>priority</td><td>{{.Priority}}</td></tr>
+<tr><td>owner</td><td>{{.Owner}}</td></tr>
+<tr><td>repositories</td><td>{{range $index, $repo := .Repos}}{{if $index}}, {{end}}<a href="/{{$repo}}/">{{$repo}}</a>{{else}}default{{end}}</td></tr>
+{{if .CloneURL}}<tr><td>clone url</td><td>{{.CloneURL}}</td></tr>{{end}}
+</table>
+{{with .Failure}}
+<p>last build failed: {{.Category}}</p>
+{{if .Excerpt}}<pre>{{.Excerpt}}</pre>{{end}}
+{{end}}
+<p>
+<a href="/ui/packages/{{.Name}}/logs">logs of the last build</a> |
+<a href="/ui/packages/{{.Name}}/live">live log</a>
+</p>
+<p>
+<img src="/ui/packages/{{.Name}}/status.svg" alt="status">
+<img src="/ui/packages/{{.Name}}/version.svg" alt="version">
+</p>
+{{end}}
+<pre>{{range .Data.Badges}}{{.}}
+{{end}}</pre>
+
+{{if .Data.Versions}}
+<h2>Versions</h2>
+<p>{{range $index, $version := .Data.Versions}}{{if $index}}, {{end}}{{$version}}{{end}}</p>
+{{end}}
+
+<h2>Builds</h2>
+<table>
+<tr><th>finished</th><th>status</th><th>version</th><th>duration</th><th>instance</th></tr>
+{{range .Data.Builds}}
+<tr>
+<td>{{time .Finished}}</td>
+<td>{{template "status" .Status}}{{with .Failure}} ({{.Category}}){{end}}</td>
+<td>{{.Version}}</td>
+<td>{{duration .Duration}}</td>
+<td>{{.Instance}}</td>
+</tr>
+{{else}}
+<tr><td colspan="5">no builds yet</td></tr>
+{{end}}
+</table>
+{{template "footer" .}}{{end}}
+
+{{define "live"}}{{template "header" .}}
+<h1><a href="/ui/packages/{{.Data.Package.Name}}">{{.Data.Package.Name}}</a>: <span id="status">connecting</span></h1>
+<pre id="log"></pre>
+<script>
+(function() {
+	var status = document.getElementById("status");
+	var log = document.getElementById("log");
+
+	var scheme = window.location.protocol == "https:" ? "wss:" : "ws:";
+	var socket = new WebSocket(
+		scheme + "//" + window.location.host +
+		"/bus/?package=" + encodeURIComponent({{.Data.Package.Name}}) +
+		"&token=" + encodeURIComponent({{.Data.Token}})
+	);
+
+	var follow = function() {
+		window.scrollTo(0, document.body.scrollHeight);
+	};
+
+	socket.onmessage = function(event) {
+		var message = JSON.parse(event.data);
+
+		switch (message.type) {
+		case "status":
+			status.textContent = message.data;
+			status.className = "status-" + message.data;
+			break;
+		case "log":
+			log.appendChild(document.createTextNode(message.data));
+			follow();
+			break;
+		case "empty_channel":
+			status.textContent = "no builds since the daemon start";
+			break;
+		case "dropped":
+			log.appendChild(document.createTextNode(
+				"\n[" + message.data + " messages dropped]\n"
+			));
+			break;
+		}
+	};
+
+	socket.onclose = function() {
+		status.textContent += " (disconnected)";
+	};
+})();
+</script>
+{{template "footer" .}}{{end}}
+`
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	defaultUpstreamInterval = 15 * time.Minute
+	upstreamTimeout         = time.Minute
+)
+
+// getCloneURL returns the repository package is built from, it's the same
+// default as in docker/run.sh.
+func getCloneURL(pkg proto.Package) string {
+	if pkg.CloneURL != "" {
+		return pkg.CloneURL
+	}
+
+	return fmt.Sprintf("https://aur.archlinux.org/%s.git", pkg.Name)
+}
+
+// hasUpstreamChanged checks HEAD of the package upstream repository not more
+// often than interval.upstream and remembers it, so pkg.Upstream is
+// updated as well. The first seen upstream is only remembered since the
+// package has been built when it was added or queued.
+func (proc *Processor) hasUpstreamChanged(pkg *proto.Package) bool {
+	interval := proc.config.Interval.Upstream
+	if interval == 0 {
+		interval = defaultUpstreamInterval
+	}
+
+	if time.Since(proc.upstreamChecks[pkg.Name]) < interval {
+		return false
+	}
+
+	proc.upstreamChecks[pkg.Name] = time.Now()
+
+	head, err := getUpstreamHead(proc.ctx, getCloneURL(*pkg))
+	if err != nil {
+		errorh(err, "unable to check upstream of %s", pkg.Name)
+		return false
+	}
+
+	if head == pkg.Upstream {
+		return false
+	}
+
+	debugf(
+		"upstream of %s has changed: %q -> %q",
+		pkg.Name, pkg.Upstream, head,
+	)
+
+	err = proc.storage.Update(
+		bson.M{"name": pkg.Name},
+		bson.M{"$set": bson.M{"upstream": head}},
+	)
+	if err != nil {
+		errorh(err, "unable to save upstream of %s", pkg.Name)
+		return false
+	}
+
+	previous := pkg.Upstream
+	pkg.Upstream = head
+
+	return previous != ""
+}
+
+func getUpstreamHead(ctx context.Context, url string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, upstreamTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(
+		ctx, "git", "ls-remote", url, "HEAD",
+	).Output()
+	if err != nil {
+		return "", karma.Format(err, "git ls-remote %s failed", url)
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("git ls-remote %s returned no HEAD", url)
+	}
+
+	return fields[0], nil
+}
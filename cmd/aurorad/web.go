@@ -6,19 +6,32 @@ import (
 	"github.com/globalsign/mgo"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
+	"github.com/kovetskiy/aurora/pkg/audit"
+	"github.com/kovetskiy/aurora/pkg/history"
+	"github.com/kovetskiy/aurora/pkg/pause"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/rpc"
+	"github.com/kovetskiy/aurora/pkg/staging"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/reconquest/karma-go"
 )
 
-const (
-	staticPrefix = "/aurora"
-)
-
-type Web struct {
-	static http.Handler
-}
-
-func serveWeb(collection *mgo.Collection, config *Config) error {
-	web := &Web{}
+func serveWeb(
+	collection *mgo.Collection,
+	trail *audit.Trail,
+	builds *history.History,
+	stages *staging.Staging,
+	pauses *pause.Queue,
+	notifier proto.Notifier,
+	config *Config,
+) error {
+	repos, err := getRepositories(config)
+	if err != nil {
+		return karma.Format(
+			err,
+			"invalid repositories configuration",
+		)
+	}
 
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
@@ -26,28 +39,82 @@ func serveWeb(collection *mgo.Collection, config *Config) error {
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 
-	web.initStatic(config)
+	for _, repo := range repos {
+		router.Get("/"+repo.Name+"/*", http.StripPrefix(
+			"/"+repo.Name,
+			http.FileServer(http.Dir(repo.Dir)),
+		).ServeHTTP)
+	}
 
-	router.Get(staticPrefix+"/*", web.static.ServeHTTP)
+	tokens, err := rpc.NewTokens(config.TokenSecret)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to initialize tokens",
+		)
+	}
 
-	rpc, err := NewRPCServer(collection, config)
+	auth, err := rpc.NewAuthService(
+		config.AuthorizedKeysDir,
+		config.Anonymous.Methods,
+		tokens,
+	)
 	if err != nil {
 		return karma.Format(
 			err,
-			"unable to create RPC server",
+			"unable to initialize AuthService",
 		)
 	}
 
-	router.Post("/rpc/", rpc.ServeHTTP)
+	packages := rpc.NewPackageService(
+		collection,
+		builds,
+		auth,
+		trail,
+		tokens,
+		notifier,
+		config.LogsDir,
+		describeRepositories(repos, config),
+		stages,
+		describeProcessor(config),
+	)
+
+	admin := rpc.NewAdminService(pauses, collection, auth, trail, config.Admins)
 
-	infof("listening at %s", config.Listen)
+	server := NewRPCServer(packages, admin, auth, trail)
 
-	return http.ListenAndServe(config.Listen, router)
-}
+	router.Post("/rpc/", server.ServeHTTP)
+
+	router.Route(apiPrefix, NewAPI(packages, admin, auth).Route)
 
-func (web *Web) initStatic(config *Config) {
-	web.static = http.StripPrefix(
-		staticPrefix,
-		http.FileServer(http.Dir(config.RepoDir)),
+	router.Route(
+		uiPrefix,
+		NewUI(collection, builds, auth, tokens, config.LogsDir).Route,
 	)
+
+	router.Get("/", func(response http.ResponseWriter, request *http.Request) {
+		http.Redirect(response, request, uiPrefix+"/", http.StatusFound)
+	})
+
+	router.Get(
+		"/bus/",
+		NewBusProxy(collection, auth, tokens, config.Instance).ServeHTTP,
+	)
+
+	registerCommonMetrics(collection, config)
+
+	router.Get("/metrics", promhttp.Handler().ServeHTTP)
+
+	health := NewHealth()
+	health.AddReady("database", checkDatabase(collection))
+	addRepositoryChecks(health, repos)
+
+	router.Get("/healthz", health.ServeLive)
+	router.Get("/readyz", health.ServeReady)
+
+	go health.NotifySystemd()
+
+	infof("listening at %s", config.Listen)
+
+	return http.ListenAndServe(config.Listen, router)
 }
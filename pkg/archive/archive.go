@@ -0,0 +1,54 @@
+package archive
+
+import (
+	"regexp"
+
+	"github.com/reconquest/regexputil-go"
+)
+
+const (
+	reTime = `(?P<time>\d+)`
+	reName = `(?P<name>[a-z0-9][a-z0-9@\._+-]+)`
+	reVer  = `(?P<ver>([0-9]+:)?[a-zA-Z0-9_.+]+-[0-9]+(\.[0-9]+)?)`
+
+	// reArch matches any architecture makepkg can produce: any, i686,
+	// x86_64, x86_64_v3, aarch64, armv7h and so on.
+	reArch = `(?P<arch>[a-z0-9_]+)`
+	reExt  = `(?P<ext>tar(\.(gz|bz2|xz|zst|lrz|lzo|sz))?)`
+)
+
+var reFilename = regexp.MustCompile(
+	`^` + reTime +
+		`\.` + reName +
+		`-` + reVer +
+		`-` + reArch +
+		`\.pkg\.` + reExt + `$`,
+)
+
+// Archive describes a package archive stored in a repository, filename of
+// the archive is prefixed with unix time of the build:
+// <time>.<name>-<pkgver>-<pkgrel>-<arch>.pkg.tar.<ext>
+type Archive struct {
+	Time    string
+	Name    string
+	Version string
+	Arch    string
+	Ext     string
+}
+
+// Parse parses basename of the archive, false is returned if it doesn't
+// look like an archive, e.g. it's a signature.
+func Parse(filename string) (Archive, bool) {
+	matches := reFilename.FindStringSubmatch(filename)
+	if matches == nil {
+		return Archive{}, false
+	}
+
+	return Archive{
+		Time:    regexputil.Subexp(reFilename, matches, "time"),
+		Name:    regexputil.Subexp(reFilename, matches, "name"),
+		Version: regexputil.Subexp(reFilename, matches, "ver"),
+		Arch:    regexputil.Subexp(reFilename, matches, "arch"),
+		Ext:     regexputil.Subexp(reFilename, matches, "ext"),
+	}, true
+}
@@ -0,0 +1,64 @@
+package archive
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	test := assert.New(t)
+
+	testcases := []struct {
+		Filename string
+		Archive  Archive
+		Valid    bool
+	}{
+		{
+			"1570000000.yay-9.4.2-1-x86_64.pkg.tar.xz",
+			Archive{"1570000000", "yay", "9.4.2-1", "x86_64", "tar.xz"},
+			true,
+		},
+		{
+			"1570000000.ttf-font-awesome-5.11.2-1-any.pkg.tar.zst",
+			Archive{"1570000000", "ttf-font-awesome", "5.11.2-1", "any", "tar.zst"},
+			true,
+		},
+		{
+			"1570000000.neovim-git-0.5.0.r1234.gabcdef-1-aarch64.pkg.tar.xz",
+			Archive{"1570000000", "neovim-git", "0.5.0.r1234.gabcdef-1", "aarch64", "tar.xz"},
+			true,
+		},
+		{
+			"1570000000.lib32-foo-1.0-2-i686.pkg.tar",
+			Archive{"1570000000", "lib32-foo", "1.0-2", "i686", "tar"},
+			true,
+		},
+		{
+			"1570000000.foo-1.0-1-armv7h.pkg.tar.gz",
+			Archive{"1570000000", "foo", "1.0-1", "armv7h", "tar.gz"},
+			true,
+		},
+		{
+			"1570000000.foo-1.0-1-x86_64_v3.pkg.tar.zst",
+			Archive{"1570000000", "foo", "1.0-1", "x86_64_v3", "tar.zst"},
+			true,
+		},
+		{
+			"1570000000.foo-2:1.0rc1-1.1-any.pkg.tar.zst",
+			Archive{"1570000000", "foo", "2:1.0rc1-1.1", "any", "tar.zst"},
+			true,
+		},
+		{"1570000000.foo-1.0-1-any.pkg.tar.xz.sig", Archive{}, false},
+		{"foo-1.0-1-any.pkg.tar.xz", Archive{}, false},
+		{"1570000000.foo-1.0-any.pkg.tar.xz", Archive{}, false},
+		{"aurora.db.tar", Archive{}, false},
+	}
+
+	for _, testcase := range testcases {
+		actual, ok := Parse(testcase.Filename)
+
+		test.Equal(testcase.Valid, ok, testcase.Filename)
+		test.Equal(testcase.Archive, actual, testcase.Filename)
+	}
+}
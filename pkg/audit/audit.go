@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/reconquest/karma-go"
+)
+
+// DefaultLimit is used when querying records without an explicit limit.
+const DefaultLimit = 100
+
+// Trail is an append-only log of actions performed by users. There are
+// no methods for updating or removing records on purpose.
+type Trail struct {
+	collection *mgo.Collection
+}
+
+func NewTrail(collection *mgo.Collection) (*Trail, error) {
+	for _, key := range [][]string{
+		{"-time"},
+		{"signer", "-time"},
+		{"params.name", "-time"},
+	} {
+		err := collection.EnsureIndex(mgo.Index{Key: key})
+		if err != nil {
+			return nil, karma.Format(
+				err,
+				"unable to ensure index %v for audit collection", key,
+			)
+		}
+	}
+
+	return &Trail{collection: collection}, nil
+}
+
+// Record appends a new record to the trail, result is the error returned
+// by the action, nil means that the action succeeded.
+func (trail *Trail) Record(
+	signer string,
+	method string,
+	source string,
+	params map[string]interface{},
+	result error,
+) error {
+	record := proto.AuditRecord{
+		Time:   time.Now(),
+		Signer: signer,
+		Method: method,
+		Params: params,
+		Source: source,
+		Result: proto.AuditResultOK,
+	}
+
+	if result != nil {
+		record.Result = result.Error()
+	}
+
+	err := trail.collection.Insert(record)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to insert audit record",
+		)
+	}
+
+	return nil
+}
+
+// Find returns the newest records matching specified signer and package
+// name, empty values match everything.
+func (trail *Trail) Find(
+	signer string,
+	pkg string,
+	limit int,
+) ([]proto.AuditRecord, error) {
+	query := bson.M{}
+	if signer != "" {
+		query["signer"] = signer
+	}
+
+	if pkg != "" {
+		query["params.name"] = pkg
+	}
+
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	records := []proto.AuditRecord{}
+
+	err := trail.collection.Find(query).Sort("-time").Limit(limit).All(&records)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to find audit records",
+		)
+	}
+
+	return records, nil
+}
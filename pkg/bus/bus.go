@@ -1,6 +1,19 @@
 package bus
 
-// Need to have const list of message types
+// TopicEvents is the firehose topic which carries events of all packages,
+// package names can't contain asterisk, so it never clashes with them.
+const TopicEvents = "*"
+
+const (
+	MessageTypeStatus       = "status"
+	MessageTypeLog          = "log"
+	MessageTypeEmptyChannel = "empty_channel"
+	MessageTypeEvent        = "event"
+
+	// MessageTypeDropped carries amount of messages that were dropped
+	// because the subscriber was too slow.
+	MessageTypeDropped = "dropped"
+)
 
 type Message struct {
 	Type string      `json:"type"`
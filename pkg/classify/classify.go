@@ -0,0 +1,167 @@
+package classify
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	CategoryMissingDependency = "missing_dependency"
+	CategoryChecksum          = "checksum"
+	CategoryDownload          = "download"
+	CategoryCompile           = "compile"
+	CategoryDiskFull          = "disk_full"
+	CategoryTimeout           = "timeout"
+	CategoryUnknown           = "unknown"
+)
+
+const (
+	// excerptContext is how many lines before the matched one are included
+	// into the excerpt, e.g. the failed command before "make: *** Error 1".
+	excerptContext = 2
+
+	// maxExcerpt limits length of the excerpt in bytes.
+	maxExcerpt = 1024
+)
+
+// Rule recognises a category of failures by a regular expression matched
+// against every line of the build log.
+type Rule struct {
+	Category string `yaml:"category" required:"true"`
+	Pattern  string `yaml:"pattern" required:"true"`
+}
+
+// DefaultRules recognise common makepkg and pacman failures, the first
+// matching rule wins, so rules of root causes go before rules of their
+// consequences.
+var DefaultRules = []Rule{
+	{CategoryDiskFull, `No space left on device`},
+
+	{CategoryMissingDependency, `error: target not found: `},
+	{CategoryMissingDependency, `could not satisfy dependencies`},
+	{CategoryMissingDependency, `unable to satisfy dependency`},
+	{CategoryMissingDependency, `==> ERROR: Could not resolve all dependencies`},
+
+	{CategoryChecksum, `==> ERROR: One or more files did not pass the validity check`},
+	{CategoryChecksum, `==> ERROR: One or more PGP signatures could not be verified`},
+	{CategoryChecksum, `\bFAILED \(unknown public key [0-9A-F]+\)`},
+	{CategoryChecksum, `is not a valid checksum`},
+
+	{CategoryDownload, `The requested URL returned error: \d+`},
+	{CategoryDownload, `==> ERROR: Failure while downloading`},
+	{CategoryDownload, `error: failed retrieving file`},
+	{CategoryDownload, `Could not resolve host`},
+
+	{CategoryCompile, `:\d+(:\d+)?: (fatal )?error: `},
+	{CategoryCompile, `^error(\[E\d+\])?: could not compile`},
+	{CategoryCompile, `^error\[E\d+\]: `},
+	{CategoryCompile, `make(\[\d+\])?: \*\*\* .*Error \d+`},
+	{CategoryCompile, `==> ERROR: A failure occurred in build\(\)`},
+}
+
+// ansi matches terminal escape sequences, makepkg colors its messages.
+var ansi = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+type rule struct {
+	category string
+	pattern  *regexp.Regexp
+}
+
+// Classifier recognises categories of failures by build logs.
+type Classifier struct {
+	rules []rule
+}
+
+// NewClassifier returns classifier which checks given rules before
+// DefaultRules.
+func NewClassifier(rules []Rule) (*Classifier, error) {
+	classifier := &Classifier{}
+
+	for _, item := range append(append([]Rule{}, rules...), DefaultRules...) {
+		if item.Category == "" {
+			return nil, karma.
+				Describe("pattern", item.Pattern).
+				Reason("category of rule is empty")
+		}
+
+		pattern, err := regexp.Compile(item.Pattern)
+		if err != nil {
+			return nil, karma.
+				Describe("category", item.Category).
+				Format(err, "invalid pattern of rule")
+		}
+
+		classifier.rules = append(classifier.rules, rule{
+			category: item.Category,
+			pattern:  pattern,
+		})
+	}
+
+	return classifier, nil
+}
+
+// Classify reads the log and returns the failure recognised by the first
+// matching rule with the matched line and a few lines before it as the
+// excerpt. The failure is of CategoryUnknown with the last lines of the log
+// if no rule matches.
+func (classifier *Classifier) Classify(log io.Reader) (*proto.Failure, error) {
+	var (
+		best    = len(classifier.rules)
+		failure = &proto.Failure{Category: CategoryUnknown}
+		recent  = []string{}
+	)
+
+	scanner := bufio.NewScanner(log)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(ansi.ReplaceAllString(scanner.Text(), ""), " \r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		for index, rule := range classifier.rules[:best] {
+			if !rule.pattern.MatchString(line) {
+				continue
+			}
+
+			best = index
+			failure.Category = rule.category
+			failure.Excerpt = getExcerpt(append(recent, line))
+
+			break
+		}
+
+		if best == 0 {
+			break
+		}
+
+		recent = append(recent, line)
+		if len(recent) > excerptContext {
+			recent = recent[1:]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, karma.Format(err, "unable to read log")
+	}
+
+	if best == len(classifier.rules) {
+		failure.Excerpt = getExcerpt(recent)
+	}
+
+	return failure, nil
+}
+
+func getExcerpt(lines []string) string {
+	excerpt := strings.Join(lines, "\n")
+	if len(excerpt) > maxExcerpt {
+		excerpt = "..." + excerpt[len(excerpt)-maxExcerpt:]
+	}
+
+	return excerpt
+}
@@ -0,0 +1,143 @@
+package classify
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifier_Classify(t *testing.T) {
+	test := assert.New(t)
+
+	classifier, err := NewClassifier(nil)
+	test.NoError(err)
+
+	testcases := []struct {
+		Log      string
+		Category string
+		Excerpt  string
+	}{
+		{
+			Log: `==> Making package: foo 1.0-1
+==> Checking runtime dependencies...
+==> Installing missing dependencies...
+error: target not found: libfoo
+==> ERROR: 'pacman' failed to install missing dependencies.`,
+			Category: CategoryMissingDependency,
+			Excerpt: `==> Checking runtime dependencies...
+==> Installing missing dependencies...
+error: target not found: libfoo`,
+		},
+		{
+			Log: `==> Validating source files with sha256sums...
+    foo-1.0.tar.gz ... FAILED
+==> ERROR: One or more files did not pass the validity check!`,
+			Category: CategoryChecksum,
+			Excerpt: `==> Validating source files with sha256sums...
+    foo-1.0.tar.gz ... FAILED
+==> ERROR: One or more files did not pass the validity check!`,
+		},
+		{
+			Log: `==> Verifying source file signatures with gpg...
+    foo-1.0.tar.gz ... FAILED (unknown public key 1234ABCD)
+==> ERROR: One or more PGP signatures could not be verified!`,
+			Category: CategoryChecksum,
+			Excerpt: `==> Verifying source file signatures with gpg...
+    foo-1.0.tar.gz ... FAILED (unknown public key 1234ABCD)
+==> ERROR: One or more PGP signatures could not be verified!`,
+		},
+		{
+			Log: `  -> Downloading foo-1.0.tar.gz...
+curl: (22) The requested URL returned error: 404
+==> ERROR: Failure while downloading https://example.com/foo-1.0.tar.gz`,
+			Category: CategoryDownload,
+			Excerpt: `  -> Downloading foo-1.0.tar.gz...
+curl: (22) The requested URL returned error: 404`,
+		},
+		{
+			Log: "gcc -c foo.c\n" +
+				"\x1b[1mfoo.c:10:5: \x1b[31merror: \x1b[0m'bar' undeclared\n" +
+				"make: *** [Makefile:2: foo.o] Error 1\n" +
+				"==> ERROR: A failure occurred in build().",
+			Category: CategoryCompile,
+			Excerpt: `gcc -c foo.c
+foo.c:10:5: error: 'bar' undeclared`,
+		},
+		{
+			Log: `foo.c:10:5: error: 'bar' undeclared
+cc1: fatal error: error writing to /tmp/ccX.s: No space left on device`,
+			Category: CategoryDiskFull,
+			Excerpt: `foo.c:10:5: error: 'bar' undeclared
+cc1: fatal error: error writing to /tmp/ccX.s: No space left on device`,
+		},
+		{
+			Log: `==> Starting build()...
+something went wrong
+
+==> ERROR: A failure occurred in build().`,
+			Category: CategoryCompile,
+			Excerpt: `==> Starting build()...
+something went wrong
+==> ERROR: A failure occurred in build().`,
+		},
+		{
+			Log: `==> Starting package()...
+install: cannot stat 'foo'
+==> ERROR: A failure occurred in package().`,
+			Category: CategoryUnknown,
+			Excerpt: `install: cannot stat 'foo'
+==> ERROR: A failure occurred in package().`,
+		},
+		{
+			Log:      ``,
+			Category: CategoryUnknown,
+			Excerpt:  ``,
+		},
+	}
+
+	for _, testcase := range testcases {
+		failure, err := classifier.Classify(strings.NewReader(testcase.Log))
+		test.NoError(err)
+		test.Equal(testcase.Category, failure.Category, testcase.Log)
+		test.Equal(testcase.Excerpt, failure.Excerpt, testcase.Log)
+	}
+}
+
+func TestClassifier_ChecksCustomRulesFirst(t *testing.T) {
+	test := assert.New(t)
+
+	classifier, err := NewClassifier([]Rule{
+		{Category: "network", Pattern: `Could not resolve host`},
+	})
+	test.NoError(err)
+
+	failure, err := classifier.Classify(strings.NewReader(
+		"curl: (6) Could not resolve host: example.com\n" +
+			"==> ERROR: Failure while downloading foo.tar.gz\n",
+	))
+	test.NoError(err)
+	test.Equal("network", failure.Category)
+	test.Equal("curl: (6) Could not resolve host: example.com", failure.Excerpt)
+
+	_, err = NewClassifier([]Rule{{Category: "broken", Pattern: `(`}})
+	test.Error(err)
+
+	_, err = NewClassifier([]Rule{{Pattern: `foo`}})
+	test.Error(err)
+}
+
+func TestClassifier_LimitsExcerpt(t *testing.T) {
+	test := assert.New(t)
+
+	classifier, err := NewClassifier(nil)
+	test.NoError(err)
+
+	failure, err := classifier.Classify(strings.NewReader(
+		strings.Repeat("x", 2*maxExcerpt) + ": No space left on device\n",
+	))
+	test.NoError(err)
+	test.Equal(CategoryDiskFull, failure.Category)
+	test.Len(failure.Excerpt, maxExcerpt+len("..."))
+	test.True(strings.HasSuffix(failure.Excerpt, "No space left on device"))
+}
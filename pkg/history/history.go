@@ -0,0 +1,192 @@
+package history
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/reconquest/karma-go"
+)
+
+// DefaultLimit is used when querying builds without an explicit limit.
+const DefaultLimit = 50
+
+// History keeps records about finished builds of packages, while package
+// itself holds only the status of its last build.
+type History struct {
+	collection *mgo.Collection
+}
+
+func NewHistory(collection *mgo.Collection) (*History, error) {
+	for _, key := range [][]string{
+		{"-finished"},
+		{"package", "-finished"},
+		{"status", "finished"},
+	} {
+		err := collection.EnsureIndex(mgo.Index{Key: key})
+		if err != nil {
+			return nil, karma.Format(
+				err,
+				"unable to ensure index %v for builds collection", key,
+			)
+		}
+	}
+
+	return &History{collection: collection}, nil
+}
+
+// Record appends a finished build to the history.
+func (history *History) Record(build proto.Build) error {
+	err := history.collection.Insert(build)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to insert build of %s", build.Package,
+		)
+	}
+
+	return nil
+}
+
+// Find returns the newest builds of specified package, empty name matches
+// all packages.
+func (history *History) Find(pkg string, limit int) ([]proto.Build, error) {
+	query := bson.M{}
+	if pkg != "" {
+		query["package"] = pkg
+	}
+
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	builds := []proto.Build{}
+
+	err := history.collection.Find(query).Sort("-finished").Limit(limit).All(&builds)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to find builds",
+		)
+	}
+
+	return builds, nil
+}
+
+// FindReleases returns the first successful build of every version, so
+// the result is a list of versions in order they were published, newest
+// first.
+func (history *History) FindReleases(limit int) ([]proto.Build, error) {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	releases := []proto.Build{}
+
+	err := history.collection.Pipe([]bson.M{
+		{"$match": bson.M{"status": proto.BuildStatusSuccess.String()}},
+		{"$sort": bson.M{"finished": 1}},
+		{"$group": bson.M{
+			"_id":   bson.M{"package": "$package", "version": "$version"},
+			"build": bson.M{"$first": "$$ROOT"},
+		}},
+		{"$replaceRoot": bson.M{"newRoot": "$build"}},
+		{"$sort": bson.M{"finished": -1}},
+		{"$limit": limit},
+	}).All(&releases)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to find releases",
+		)
+	}
+
+	return releases, nil
+}
+
+// FindDurations returns average duration of the last successful builds of
+// every package, at most limit builds of each package are taken.
+func (history *History) FindDurations(limit int) (map[string]time.Duration, error) {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	var averages []struct {
+		Package  string  `bson:"_id"`
+		Duration float64 `bson:"duration"`
+	}
+
+	err := history.collection.Pipe([]bson.M{
+		{"$match": bson.M{"status": proto.BuildStatusSuccess.String()}},
+		{"$sort": bson.M{"finished": -1}},
+		{"$group": bson.M{
+			"_id":       "$package",
+			"durations": bson.M{"$push": "$duration"},
+		}},
+		{"$project": bson.M{
+			"duration": bson.M{
+				"$avg": bson.M{"$slice": []interface{}{"$durations", limit}},
+			},
+		}},
+	}).All(&averages)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to find durations of builds",
+		)
+	}
+
+	durations := map[string]time.Duration{}
+	for _, average := range averages {
+		durations[average.Package] = time.Duration(average.Duration)
+	}
+
+	return durations, nil
+}
+
+// FindPrevious returns the successful build which was published before the
+// currently published build of the package.
+func (history *History) FindPrevious(pkg proto.Package) (proto.Build, bool, error) {
+	builds, err := history.Find(pkg.Name, 0)
+	if err != nil {
+		return proto.Build{}, false, err
+	}
+
+	build, ok := getPrevious(pkg, builds)
+
+	return build, ok, nil
+}
+
+// getPrevious finds the currently published build in builds sorted from the
+// newest to the oldest one and returns the next successful build. Builds
+// recorded without archive are told apart by version only.
+func getPrevious(pkg proto.Package, builds []proto.Build) (proto.Build, bool) {
+	isCurrent := func(build proto.Build) bool {
+		if pkg.Archive != "" && build.Archive != "" {
+			return build.Archive == pkg.Archive
+		}
+
+		return build.Version == pkg.Version
+	}
+
+	found := false
+	for _, build := range builds {
+		if build.Status != proto.BuildStatusSuccess.String() {
+			continue
+		}
+
+		if !found {
+			found = isCurrent(build)
+			continue
+		}
+
+		if isCurrent(build) {
+			continue
+		}
+
+		return build, true
+	}
+
+	return proto.Build{}, false
+}
@@ -0,0 +1,68 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPrevious(t *testing.T) {
+	test := assert.New(t)
+
+	success := proto.BuildStatusSuccess.String()
+	failure := proto.BuildStatusFailure.String()
+
+	builds := []proto.Build{
+		{Status: failure, Version: "1.3-1"},
+		{Status: success, Version: "1.2-1", Archive: "3.foo-1.2-1-any.pkg.tar.xz"},
+		{Status: success, Version: "1.2-1", Archive: "2.foo-1.2-1-any.pkg.tar.xz"},
+		{Status: failure, Version: "1.2-1"},
+		{Status: success, Version: "1.1-1"},
+		{Status: success, Version: "1.0-1"},
+	}
+
+	testcases := []struct {
+		Package  proto.Package
+		Previous proto.Build
+		Found    bool
+	}{
+		{
+			proto.Package{Version: "1.2-1", Archive: "3.foo-1.2-1-any.pkg.tar.xz"},
+			builds[2],
+			true,
+		},
+		{
+			proto.Package{Version: "1.2-1", Archive: "2.foo-1.2-1-any.pkg.tar.xz"},
+			builds[4],
+			true,
+		},
+		{
+			proto.Package{Version: "1.2-1"},
+			builds[4],
+			true,
+		},
+		{
+			proto.Package{Version: "1.1-1"},
+			builds[5],
+			true,
+		},
+		{
+			proto.Package{Version: "1.0-1"},
+			proto.Build{},
+			false,
+		},
+		{
+			proto.Package{Version: "0.9-1"},
+			proto.Build{},
+			false,
+		},
+	}
+
+	for _, testcase := range testcases {
+		previous, found := getPrevious(testcase.Package, builds)
+
+		test.Equal(testcase.Found, found, testcase.Package.Version)
+		test.Equal(testcase.Previous, previous, testcase.Package.Version)
+	}
+}
@@ -0,0 +1,411 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/lorg"
+	"github.com/reconquest/karma-go"
+)
+
+const defaultLogTail = 100
+
+// SMTP describes relay used for sending mail.
+type SMTP struct {
+	Address  string `yaml:"address"`
+	From     string `yaml:"from"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// User is notification preferences of a user, users are identified by
+// names of their keys in authorized_keys.
+type User struct {
+	Email    string `yaml:"email" required:"true"`
+	Failures bool   `yaml:"failures"`
+	Digest   bool   `yaml:"digest"`
+}
+
+// Mailer sends mail to the owner of a package when its build goes from
+// success to failure or the package is quarantined and collects failures and
+// version changes of all packages into a daily digest.
+type Mailer struct {
+	smtp    SMTP
+	users   map[string]User
+	logsDir string
+	logTail int
+	log     *lorg.Log
+
+	mutex  *sync.Mutex
+	digest []proto.Event
+}
+
+func NewMailer(
+	smtp SMTP,
+	users map[string]User,
+	logsDir string,
+	logTail int,
+	log *lorg.Log,
+) *Mailer {
+	if logTail <= 0 {
+		logTail = defaultLogTail
+	}
+
+	return &Mailer{
+		smtp:    smtp,
+		users:   users,
+		logsDir: logsDir,
+		logTail: logTail,
+		log:     log,
+		mutex:   &sync.Mutex{},
+	}
+}
+
+func (mailer *Mailer) Notify(event proto.Event) {
+	switch {
+	case event.Type == proto.EventVersionChanged:
+		mailer.collect(event)
+
+	case event.Type == proto.EventBuildFinished &&
+		event.Status == proto.BuildStatusFailure.String():
+		mailer.collect(event)
+
+		if event.PreviousStatus == proto.BuildStatusSuccess.String() {
+			go mailer.sendFailure(event)
+		}
+
+	case event.Type == proto.EventPackageQuarantined:
+		go mailer.sendQuarantine(event)
+	}
+}
+
+func (mailer *Mailer) collect(event proto.Event) {
+	mailer.mutex.Lock()
+	defer mailer.mutex.Unlock()
+
+	mailer.digest = append(mailer.digest, event)
+}
+
+func (mailer *Mailer) sendFailure(event proto.Event) {
+	mailer.sendWithLogTail(
+		event,
+		fmt.Sprintf("aurora: %s build failed", event.Package),
+		fmt.Sprintf(
+			"Build of %s %s has failed at %s after %s.\n%s\n"+
+				"Previous build was successful, "+
+				"last %d lines of the log are attached.\n",
+			event.Package,
+			event.Version,
+			event.Instance,
+			event.Duration.Round(time.Second),
+			formatFailure(event.Failure),
+			mailer.logTail,
+		),
+	)
+}
+
+func (mailer *Mailer) sendQuarantine(event proto.Event) {
+	mailer.sendWithLogTail(
+		event,
+		fmt.Sprintf("aurora: %s has been quarantined", event.Package),
+		fmt.Sprintf(
+			"Package %s has failed %d times in a row and won't be rebuilt "+
+				"until its upstream changes or it's rebuilt manually "+
+				"with aurora rebuild %s.\n%s\n"+
+				"Last %d lines of the log are attached.\n",
+			event.Package,
+			event.Failures,
+			event.Package,
+			formatFailure(event.Failure),
+			mailer.logTail,
+		),
+	)
+}
+
+// formatFailure describes why the build has failed with the excerpt of the
+// log indented.
+func formatFailure(failure *proto.Failure) string {
+	if failure == nil {
+		return ""
+	}
+
+	buffer := &bytes.Buffer{}
+	fmt.Fprintf(buffer, "\nFailure: %s\n", failure.Category)
+
+	if failure.Excerpt != "" {
+		fmt.Fprintln(buffer)
+
+		for _, line := range strings.Split(failure.Excerpt, "\n") {
+			fmt.Fprintf(buffer, "    %s\n", line)
+		}
+	}
+
+	return buffer.String()
+}
+
+// sendWithLogTail sends mail to the owner of the package if the owner wants
+// to know about failures.
+func (mailer *Mailer) sendWithLogTail(
+	event proto.Event,
+	subject string,
+	body string,
+) {
+	user, ok := mailer.users[event.Owner]
+	if !ok || !user.Failures {
+		return
+	}
+
+	tail, err := mailer.readLogTail(event.Package)
+	if err != nil {
+		mailer.log.Warning(
+			karma.Format(err, "unable to read logs of %s", event.Package),
+		)
+	}
+
+	err = mailer.send(user.Email, subject, body, event.Package+".log", tail)
+	if err != nil {
+		mailer.log.Error(
+			karma.Format(err, "unable to send mail to %s", user.Email),
+		)
+	}
+}
+
+// RunDigest sends digest every day at specified time of day (the date part
+// is ignored).
+func (mailer *Mailer) RunDigest(at time.Time) {
+	for {
+		now := time.Now()
+
+		next := time.Date(
+			now.Year(), now.Month(), now.Day(),
+			at.Hour(), at.Minute(), 0, 0, now.Location(),
+		)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+
+		time.Sleep(next.Sub(now))
+
+		mailer.SendDigest()
+	}
+}
+
+// SendDigest sends collected events to every user who wants the digest and
+// starts collecting from scratch.
+func (mailer *Mailer) SendDigest() {
+	mailer.mutex.Lock()
+	events := mailer.digest
+	mailer.digest = nil
+	mailer.mutex.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+
+	body := formatDigest(events)
+
+	names := []string{}
+	for name := range mailer.users {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		user := mailer.users[name]
+		if !user.Digest {
+			continue
+		}
+
+		err := mailer.send(user.Email, "aurora: daily digest", body, "", nil)
+		if err != nil {
+			mailer.log.Error(
+				karma.Format(err, "unable to send digest to %s", user.Email),
+			)
+		}
+	}
+}
+
+func formatDigest(events []proto.Event) string {
+	var failures, versions []string
+
+	for _, event := range events {
+		at := event.Time.Format("2006-01-02 15:04")
+
+		switch event.Type {
+		case proto.EventVersionChanged:
+			versions = append(versions, fmt.Sprintf(
+				"  %s  %s: %s -> %s",
+				at, event.Package, event.PreviousVersion, event.Version,
+			))
+		default:
+			failure := fmt.Sprintf("  %s  %s %s", at, event.Package, event.Version)
+			if event.Failure != nil {
+				failure += " (" + event.Failure.Category + ")"
+			}
+
+			failures = append(failures, failure)
+		}
+	}
+
+	buffer := &bytes.Buffer{}
+	fmt.Fprintf(buffer, "Failed builds (%d):\n", len(failures))
+	fmt.Fprintln(buffer, strings.Join(failures, "\n"))
+	fmt.Fprintf(buffer, "\nNew versions (%d):\n", len(versions))
+	fmt.Fprintln(buffer, strings.Join(versions, "\n"))
+
+	return buffer.String()
+}
+
+func (mailer *Mailer) readLogTail(name string) ([]byte, error) {
+	file, err := os.Open(filepath.Join(mailer.logsDir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	lines := []string{}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > mailer.logTail {
+			lines = lines[1:]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+func (mailer *Mailer) send(
+	to string,
+	subject string,
+	body string,
+	attachmentName string,
+	attachment []byte,
+) error {
+	message, err := composeMessage(
+		mailer.smtp.From, to, subject, body, attachmentName, attachment,
+	)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if mailer.smtp.Username != "" {
+		host := mailer.smtp.Address
+		if index := strings.LastIndex(host, ":"); index >= 0 {
+			host = host[:index]
+		}
+
+		auth = smtp.PlainAuth(
+			"", mailer.smtp.Username, mailer.smtp.Password, host,
+		)
+	}
+
+	err = smtp.SendMail(
+		mailer.smtp.Address, auth, mailer.smtp.From, []string{to}, message,
+	)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to send mail via %s", mailer.smtp.Address,
+		)
+	}
+
+	return nil
+}
+
+func composeMessage(
+	from string,
+	to string,
+	subject string,
+	body string,
+	attachmentName string,
+	attachment []byte,
+) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	writer := multipart.NewWriter(buffer)
+
+	fmt.Fprintf(buffer, "From: %s\r\n", from)
+	fmt.Fprintf(buffer, "To: %s\r\n", to)
+	fmt.Fprintf(buffer, "Subject: %s\r\n", subject)
+	fmt.Fprintf(buffer, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(buffer, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(
+		buffer,
+		"Content-Type: multipart/mixed; boundary=%s\r\n\r\n",
+		writer.Boundary(),
+	)
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/plain; charset=utf-8"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = part.Write([]byte(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if attachment != nil {
+		part, err = writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"text/plain; charset=utf-8"},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition": {
+				fmt.Sprintf("attachment; filename=%q", attachmentName),
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encoded := base64.StdEncoding.EncodeToString(attachment)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// ParseDigestTime parses time of day in 15:04 format.
+func ParseDigestTime(value string) (time.Time, error) {
+	at, err := time.Parse("15:04", value)
+	if err != nil {
+		return time.Time{}, karma.Format(
+			err,
+			"unable to parse digest time %q, expected HH:MM", value,
+		)
+	}
+
+	return at, nil
+}
@@ -0,0 +1,232 @@
+package mail
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/lorg"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSMTP accepts connections and sends every received message to the
+// channel.
+func fakeSMTP(t *testing.T) (string, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := make(chan string, 10)
+
+	go func() {
+		for {
+			connection, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveSMTP(connection, messages)
+		}
+	}()
+
+	return listener.Addr().String(), messages
+}
+
+func serveSMTP(connection net.Conn, messages chan string) {
+	defer connection.Close()
+
+	reader := bufio.NewReader(connection)
+	reply := func(line string) {
+		connection.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost fake")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"),
+			strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "DATA"):
+			reply("354 go ahead")
+
+			data := []string{}
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+
+				if strings.TrimRight(line, "\r\n") == "." {
+					break
+				}
+
+				data = append(data, line)
+			}
+
+			messages <- strings.Join(data, "")
+
+			reply("250 ok")
+		case strings.HasPrefix(command, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestMailer_SendsFailureWithLogTail(t *testing.T) {
+	test := assert.New(t)
+
+	address, messages := fakeSMTP(t)
+
+	logsDir, err := ioutil.TempDir("", "aurora-mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(logsDir)
+
+	err = ioutil.WriteFile(
+		filepath.Join(logsDir, "foo"),
+		[]byte("line 1\nline 2\nline 3\n"),
+		0644,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mailer := NewMailer(
+		SMTP{Address: address, From: "aurora@localhost"},
+		map[string]User{
+			"alice": {Email: "alice@localhost", Failures: true},
+		},
+		logsDir,
+		2,
+		lorg.NewLog(),
+	)
+
+	mailer.Notify(proto.Event{
+		Type:           proto.EventBuildFinished,
+		Package:        "foo",
+		Owner:          "alice",
+		Status:         proto.BuildStatusFailure.String(),
+		PreviousStatus: proto.BuildStatusSuccess.String(),
+		Failure: &proto.Failure{
+			Category: "compile",
+			Excerpt:  "foo.c:1:1: error: oops",
+		},
+	})
+
+	select {
+	case message := <-messages:
+		test.Contains(message, "To: alice@localhost")
+		test.Contains(message, "Subject: aurora: foo build failed")
+		test.Contains(message, "Failure: compile")
+		test.Contains(message, "    foo.c:1:1: error: oops")
+		test.Contains(message, `filename="foo.log"`)
+		// base64 of "line 2\nline 3\n"
+		test.Contains(message, "bGluZSAyCmxpbmUgMwo=")
+	case <-time.After(5 * time.Second):
+		test.Fail("mail was not sent")
+	}
+}
+
+func TestMailer_SendsQuarantine(t *testing.T) {
+	test := assert.New(t)
+
+	address, messages := fakeSMTP(t)
+
+	mailer := NewMailer(
+		SMTP{Address: address, From: "aurora@localhost"},
+		map[string]User{
+			"alice": {Email: "alice@localhost", Failures: true},
+		},
+		"",
+		2,
+		lorg.NewLog(),
+	)
+
+	mailer.Notify(proto.Event{
+		Type:     proto.EventPackageQuarantined,
+		Package:  "foo",
+		Owner:    "alice",
+		Status:   proto.BuildStatusFailure.String(),
+		Failures: 10,
+	})
+
+	select {
+	case message := <-messages:
+		test.Contains(message, "To: alice@localhost")
+		test.Contains(message, "Subject: aurora: foo has been quarantined")
+		test.Contains(message, "failed 10 times in a row")
+	case <-time.After(5 * time.Second):
+		test.Fail("mail was not sent")
+	}
+}
+
+func TestMailer_CollectsDigest(t *testing.T) {
+	test := assert.New(t)
+
+	address, messages := fakeSMTP(t)
+
+	mailer := NewMailer(
+		SMTP{Address: address, From: "aurora@localhost"},
+		map[string]User{
+			"alice": {Email: "alice@localhost", Digest: true},
+			"bob":   {Email: "bob@localhost"},
+		},
+		"",
+		0,
+		lorg.NewLog(),
+	)
+
+	// failure after failure doesn't send immediate mail
+	mailer.Notify(proto.Event{
+		Type:           proto.EventBuildFinished,
+		Package:        "foo",
+		Owner:          "bob",
+		Status:         proto.BuildStatusFailure.String(),
+		PreviousStatus: proto.BuildStatusFailure.String(),
+		Failure:        &proto.Failure{Category: "download"},
+	})
+
+	mailer.Notify(proto.Event{
+		Type:            proto.EventVersionChanged,
+		Package:         "bar",
+		Version:         "1.1-1",
+		PreviousVersion: "1.0-1",
+	})
+
+	mailer.SendDigest()
+
+	select {
+	case message := <-messages:
+		test.Contains(message, "To: alice@localhost")
+		test.Contains(message, "Failed builds (1)")
+		test.Contains(message, "foo  (download)")
+		test.Contains(message, "bar: 1.0-1 -> 1.1-1")
+	case <-time.After(5 * time.Second):
+		test.Fail("digest was not sent")
+	}
+
+	select {
+	case message := <-messages:
+		test.Fail("unexpected mail", message)
+	default:
+	}
+}
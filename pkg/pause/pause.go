@@ -0,0 +1,178 @@
+package pause
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	// queueID is id of the document which holds pause of the whole queue.
+	queueID = "queue"
+
+	// processorPrefix prefixes ids of documents which hold state of
+	// processor instances.
+	processorPrefix = "processor:"
+)
+
+// processor is state of a processor instance, it's recorded by the
+// processor itself, so draining the queue can wait for every instance.
+type processor struct {
+	ID string `bson:"_id"`
+
+	// Stopped is the start of the pause for which the processor has stopped
+	// dispatching builds and has no running builds.
+	Stopped time.Time `bson:"stopped"`
+}
+
+// Queue keeps paused state of the build queue in the database, so it
+// survives restarts and is shared by all instances.
+type Queue struct {
+	collection *mgo.Collection
+}
+
+func NewQueue(collection *mgo.Collection) *Queue {
+	return &Queue{collection: collection}
+}
+
+// Get returns pause of the queue or nil if the queue is not paused.
+func (queue *Queue) Get() (*proto.Pause, error) {
+	var pause proto.Pause
+
+	err := queue.collection.FindId(queueID).One(&pause)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to find pause of the queue",
+		)
+	}
+
+	return &pause, nil
+}
+
+// Pause stops dispatching of new builds, pausing already paused queue
+// updates the reason only.
+func (queue *Queue) Pause(pause proto.Pause) error {
+	current, err := queue.Get()
+	if err != nil {
+		return err
+	}
+
+	if current != nil {
+		pause.Since = current.Since
+	}
+
+	_, err = queue.collection.UpsertId(queueID, pause)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to pause the queue",
+		)
+	}
+
+	return nil
+}
+
+// Resume lets the processor dispatch builds again.
+func (queue *Queue) Resume() error {
+	err := queue.collection.RemoveId(queueID)
+	if err != nil && err != mgo.ErrNotFound {
+		return karma.Format(
+			err,
+			"unable to resume the queue",
+		)
+	}
+
+	return nil
+}
+
+// Register records the processor instance, draining waits for registered
+// instances only.
+func (queue *Queue) Register(instance string) error {
+	_, err := queue.collection.UpsertId(
+		processorPrefix+instance,
+		processor{ID: processorPrefix + instance},
+	)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to register processor %s", instance,
+		)
+	}
+
+	return nil
+}
+
+// Unregister removes the processor instance, it's called on shutdown.
+func (queue *Queue) Unregister(instance string) error {
+	err := queue.collection.RemoveId(processorPrefix + instance)
+	if err != nil && err != mgo.ErrNotFound {
+		return karma.Format(
+			err,
+			"unable to unregister processor %s", instance,
+		)
+	}
+
+	return nil
+}
+
+// Stop records that the processor instance has stopped dispatching builds
+// for the pause and has no running builds.
+func (queue *Queue) Stop(instance string, pause proto.Pause) error {
+	_, err := queue.collection.UpsertId(
+		processorPrefix+instance,
+		processor{ID: processorPrefix + instance, Stopped: pause.Since},
+	)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to record stop of processor %s", instance,
+		)
+	}
+
+	return nil
+}
+
+// GetDispatching returns registered processor instances which have not
+// stopped for the pause yet.
+func (queue *Queue) GetDispatching(pause proto.Pause) ([]string, error) {
+	processors := []processor{}
+
+	err := queue.collection.Find(
+		bson.M{"_id": bson.M{"$regex": "^" + processorPrefix}},
+	).All(&processors)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to find processors",
+		)
+	}
+
+	return getDispatching(processors, pause), nil
+}
+
+func getDispatching(processors []processor, pause proto.Pause) []string {
+	instances := []string{}
+	for _, processor := range processors {
+		if processor.Stopped.Equal(pause.Since) {
+			continue
+		}
+
+		instances = append(
+			instances,
+			strings.TrimPrefix(processor.ID, processorPrefix),
+		)
+	}
+
+	sort.Strings(instances)
+
+	return instances
+}
@@ -0,0 +1,33 @@
+package pause
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDispatching(t *testing.T) {
+	test := assert.New(t)
+
+	since := time.Date(2021, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	processors := []processor{
+		{ID: processorPrefix + "c"},
+		{ID: processorPrefix + "a", Stopped: since},
+		{ID: processorPrefix + "b", Stopped: since.Add(-time.Hour)},
+	}
+
+	test.Equal(
+		[]string{"b", "c"},
+		getDispatching(processors, proto.Pause{Since: since.Local()}),
+	)
+
+	test.Equal(
+		[]string{"a", "b", "c"},
+		getDispatching(processors, proto.Pause{Since: since.Add(time.Hour)}),
+	)
+
+	test.Empty(getDispatching(nil, proto.Pause{Since: since}))
+}
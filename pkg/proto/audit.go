@@ -0,0 +1,16 @@
+package proto
+
+import "time"
+
+// AuditRecord describes a single action performed against aurora.
+type AuditRecord struct {
+	Time   time.Time              `bson:"time" json:"time"`
+	Signer string                 `bson:"signer" json:"signer"`
+	Method string                 `bson:"method" json:"method"`
+	Params map[string]interface{} `bson:"params" json:"params"`
+	Source string                 `bson:"source" json:"source"`
+	Result string                 `bson:"result" json:"result"`
+}
+
+// AuditResultOK is stored as the result of actions finished without errors.
+const AuditResultOK = "ok"
@@ -0,0 +1,20 @@
+package proto
+
+import "time"
+
+// Build is a record about a single finished build of a package.
+type Build struct {
+	Package  string        `bson:"package" json:"package"`
+	Instance string        `bson:"instance" json:"instance"`
+	Status   string        `bson:"status" json:"status"`
+	Version  string        `bson:"version" json:"version"`
+	Started  time.Time     `bson:"started" json:"started"`
+	Finished time.Time     `bson:"finished" json:"finished"`
+	Duration time.Duration `bson:"duration" json:"duration"`
+
+	// Archive is basename of the published archive of successful build.
+	Archive string `bson:"archive" json:"archive,omitempty"`
+
+	// Failure describes why failed build has failed.
+	Failure *Failure `bson:"failure,omitempty" json:"failure,omitempty"`
+}
@@ -0,0 +1,75 @@
+package proto
+
+import "time"
+
+const (
+	// EventStatus is sent when the status of a package is changed.
+	EventStatus = "status"
+
+	EventBuildStarted  = "build_started"
+	EventBuildFinished = "build_finished"
+
+	// EventVersionChanged is sent when a build produced a new version.
+	EventVersionChanged = "version_changed"
+
+	EventPackageAdded   = "package_added"
+	EventPackageRemoved = "package_removed"
+
+	// EventPackagePromoted is sent when a version of a package is promoted
+	// from a testing repository to Repo.
+	EventPackagePromoted = "package_promoted"
+
+	// EventPackageQuarantined is sent when a package is not rebuilt anymore
+	// after too many consecutive failures.
+	EventPackageQuarantined = "package_quarantined"
+)
+
+// Notifier receives events of packages, e.g. to deliver them to external
+// services.
+type Notifier interface {
+	Notify(event Event)
+}
+
+// Notifiers passes events to every notifier in the list.
+type Notifiers []Notifier
+
+func (notifiers Notifiers) Notify(event Event) {
+	for _, notifier := range notifiers {
+		notifier.Notify(event)
+	}
+}
+
+// Event describes something that happened with a package, events of all
+// packages are published in the bus to the firehose topic.
+type Event struct {
+	Type            string        `json:"type"`
+	Package         string        `json:"package"`
+	Owner           string        `json:"owner,omitempty"`
+	Private         bool          `json:"private,omitempty"`
+	Status          string        `json:"status,omitempty"`
+	PreviousStatus  string        `json:"previous_status,omitempty"`
+	Version         string        `json:"version,omitempty"`
+	PreviousVersion string        `json:"previous_version,omitempty"`
+	Instance        string        `json:"instance,omitempty"`
+	Repo            string        `json:"repo,omitempty"`
+	Duration        time.Duration `json:"duration,omitempty"`
+	Failures        int           `json:"failures,omitempty"`
+	Failure         *Failure      `json:"failure,omitempty"`
+	Time            time.Time     `json:"time"`
+}
+
+// NewEvent returns event of specified type filled with package info.
+func NewEvent(kind string, pkg Package) Event {
+	return Event{
+		Type:     kind,
+		Package:  pkg.Name,
+		Owner:    pkg.Owner,
+		Private:  pkg.Private,
+		Status:   pkg.Status,
+		Version:  pkg.Version,
+		Instance: pkg.Instance,
+		Failures: pkg.Failures,
+		Failure:  pkg.Failure,
+		Time:     time.Now(),
+	}
+}
@@ -0,0 +1,10 @@
+package proto
+
+// Failure describes why a build has failed, it's recognised by the build
+// log.
+type Failure struct {
+	Category string `bson:"category" json:"category"`
+
+	// Excerpt is the part of the log which explains the failure.
+	Excerpt string `bson:"excerpt" json:"excerpt,omitempty"`
+}
@@ -10,4 +10,75 @@ type Package struct {
 	Instance string    `bson:"instance" json:"instance"`
 	Date     time.Time `bson:"date" json:"date"`
 	Priority int       `bson:"priority" json:"priority"`
+	Private  bool      `bson:"private" json:"private,omitempty"`
+	Owner    string    `bson:"owner" json:"owner,omitempty"`
+	Repos    []string  `bson:"repos" json:"repos,omitempty"`
+
+	// Archive is basename of the published archive.
+	Archive string `bson:"archive" json:"archive,omitempty"`
+
+	// Frozen package is not rebuilt, Pin republishes an archived build and
+	// freezes the package.
+	Frozen bool `bson:"frozen" json:"frozen,omitempty"`
+	Pin    *Pin `bson:"pin,omitempty" json:"pin,omitempty"`
+
+	// Paused package is not dispatched until resumed, unlike freezing it's
+	// meant for short maintenance.
+	Paused *Pause `bson:"paused,omitempty" json:"paused,omitempty"`
+
+	// Schedule overrides global rebuild intervals, Next is the time of the
+	// next planned build and Upstream is the last seen upstream revision.
+	Schedule *Schedule `bson:"schedule,omitempty" json:"schedule,omitempty"`
+	Next     time.Time `bson:"next" json:"next"`
+	Upstream string    `bson:"upstream" json:"upstream,omitempty"`
+
+	// Failures is the number of consecutive failed builds, quarantined
+	// package is rebuilt only on upstream change or manual rebuild.
+	Failures    int  `bson:"failures,omitempty" json:"failures,omitempty"`
+	Quarantined bool `bson:"quarantined,omitempty" json:"quarantined,omitempty"`
+
+	// Failure describes why the last build has failed.
+	Failure *Failure `bson:"failure,omitempty" json:"failure,omitempty"`
+
+	// Timeout, Resources and Env override global settings of build
+	// containers.
+	Timeout   time.Duration     `bson:"timeout,omitempty" json:"timeout,omitempty"`
+	Resources *Resources        `bson:"resources,omitempty" json:"resources,omitempty"`
+	Env       map[string]string `bson:"env,omitempty" json:"env,omitempty"`
+
+	// Class is a resource class which limits how many builds of such
+	// packages can be run concurrently on one instance.
+	Class string `bson:"class,omitempty" json:"class,omitempty"`
+}
+
+// Resources limits build container, zero means global limit.
+type Resources struct {
+	CPU float64 `bson:"cpu,omitempty" json:"cpu,omitempty"`
+
+	// Memory is in bytes.
+	Memory int64 `bson:"memory,omitempty" json:"memory,omitempty"`
+}
+
+// Schedule describes when a package should be rebuilt, only one of the
+// fields is expected to be set.
+type Schedule struct {
+	Cron     string        `bson:"cron,omitempty" json:"cron,omitempty"`
+	Interval time.Duration `bson:"interval,omitempty" json:"interval,omitempty"`
+
+	// Upstream package is rebuilt only when its upstream repository changes.
+	Upstream bool `bson:"upstream,omitempty" json:"upstream,omitempty"`
+}
+
+// Pin describes archived build of a package which is published instead of
+// the latest one.
+type Pin struct {
+	Version string `bson:"version" json:"version"`
+
+	// Archive is basename of particular build, the newest build of the
+	// version is used if it's empty.
+	Archive string `bson:"archive" json:"archive,omitempty"`
+
+	// Pending is set until the processor republishes the archive.
+	Pending bool   `bson:"pending" json:"pending,omitempty"`
+	Error   string `bson:"error" json:"error,omitempty"`
 }
@@ -1,6 +1,8 @@
 package proto
 
 import (
+	"time"
+
 	"github.com/kovetskiy/aurora/pkg/signature"
 )
 
@@ -23,12 +25,41 @@ type RequestGetLogs struct {
 type RequestGetBus struct {
 	Signature *signature.Signature `json:"signature"`
 	Name      string               `json:"name"`
+
+	// All requests the firehose stream with events of all packages.
+	All bool `json:"all,omitempty"`
 }
 
 type RequestAddPackage struct {
 	Signature *signature.Signature `json:"signature"`
 	Name      string               `json:"name"`
 	CloneURL  string               `json:"clone_url,omitempty"`
+	Private   bool                 `json:"private,omitempty"`
+	Priority  int                  `json:"priority,omitempty"`
+	Repos     []string             `json:"repos,omitempty"`
+
+	// Schedule is a cron expression, "every <duration>" or "upstream".
+	Schedule string `json:"schedule,omitempty"`
+}
+
+// RequestUpdatePackage changes only specified options, empty values reset
+// options to defaults.
+type RequestUpdatePackage struct {
+	Signature *signature.Signature `json:"signature"`
+	Name      string               `json:"name"`
+
+	Priority *int           `json:"priority,omitempty"`
+	CloneURL *string        `json:"clone_url,omitempty"`
+	Schedule *string        `json:"schedule,omitempty"`
+	Timeout  *time.Duration `json:"timeout,omitempty"`
+	CPU      *float64       `json:"cpu,omitempty"`
+	Memory   *int64         `json:"memory,omitempty"`
+	Class    *string        `json:"class,omitempty"`
+	Repos    *[]string      `json:"repos,omitempty"`
+
+	// Env sets environment variables of build container, variables with
+	// empty values are removed.
+	Env map[string]string `json:"env,omitempty"`
 }
 
 type RequestRemovePackage struct {
@@ -36,12 +67,74 @@ type RequestRemovePackage struct {
 	Name      string               `json:"name"`
 }
 
+type RequestRebuildPackage struct {
+	Signature *signature.Signature `json:"signature"`
+	Name      string               `json:"name"`
+}
+
+type RequestPromotePackage struct {
+	Signature *signature.Signature `json:"signature"`
+	Name      string               `json:"name"`
+	Version   string               `json:"version,omitempty"`
+}
+
+type RequestReportProblem struct {
+	Signature *signature.Signature `json:"signature"`
+	Name      string               `json:"name"`
+	Problem   string               `json:"problem"`
+}
+
+type RequestPinPackage struct {
+	Signature *signature.Signature `json:"signature"`
+	Name      string               `json:"name"`
+	Version   string               `json:"version"`
+}
+
+type RequestFreezePackage struct {
+	Signature *signature.Signature `json:"signature"`
+	Name      string               `json:"name"`
+	Frozen    bool                 `json:"frozen"`
+}
+
+type RequestRollbackPackage struct {
+	Signature *signature.Signature `json:"signature"`
+	Name      string               `json:"name"`
+}
+
+type RequestPausePackage struct {
+	Signature *signature.Signature `json:"signature"`
+	Name      string               `json:"name"`
+	Paused    bool                 `json:"paused"`
+	Reason    string               `json:"reason,omitempty"`
+}
+
+type RequestPauseQueue struct {
+	Signature *signature.Signature `json:"signature"`
+	Paused    bool                 `json:"paused"`
+	Reason    string               `json:"reason,omitempty"`
+}
+
+type RequestGetStatus struct {
+	Signature *signature.Signature `json:"signature"`
+}
+
+type RequestListBuilds struct {
+	Signature *signature.Signature `json:"signature"`
+	Name      string               `json:"name"`
+	Limit     int                  `json:"limit,omitempty"`
+}
+
+type RequestGetQueue struct {
+	Signature *signature.Signature `json:"signature"`
+}
+
 type ResponseListPackages struct {
 	Packages []*Package `json:"packages"`
 }
 
 type ResponseGetPackage struct {
 	Package *Package `json:"package"`
+	Stages  []Stage  `json:"stages,omitempty"`
 }
 
 type ResponseGetLogs struct {
@@ -54,8 +147,48 @@ type ResponseGetBus struct {
 
 type ResponseAddPackage struct{}
 
+type ResponseUpdatePackage struct{}
+
 type ResponseRemovePackage struct{}
 
+type ResponseRebuildPackage struct{}
+
+type ResponsePromotePackage struct{}
+
+type ResponseReportProblem struct{}
+
+type ResponsePinPackage struct{}
+
+type ResponseFreezePackage struct{}
+
+type ResponseRollbackPackage struct {
+	Version string `json:"version"`
+}
+
+type ResponsePausePackage struct{}
+
+type ResponsePauseQueue struct{}
+
+type ResponseGetStatus struct {
+	// Pause is nil unless the queue is paused.
+	Pause *Pause `json:"pause,omitempty"`
+
+	// Running lists packages which are being built now.
+	Running []string `json:"running"`
+
+	// Dispatching lists processor instances which have not stopped for the
+	// pause yet, they can start builds until then.
+	Dispatching []string `json:"dispatching,omitempty"`
+}
+
+type ResponseListBuilds struct {
+	Builds []Build `json:"builds"`
+}
+
+type ResponseGetQueue struct {
+	Queue []QueueItem `json:"queue"`
+}
+
 type RequestWhoAmI struct {
 	Signature *signature.Signature `json:"signature"`
 }
@@ -63,3 +196,30 @@ type RequestWhoAmI struct {
 type ResponseWhoAmI struct {
 	Name string `json:"name"`
 }
+
+type RequestIssueToken struct {
+	Signature *signature.Signature `json:"signature"`
+
+	// TTL is how long the token should be valid, e.g. "720h".
+	TTL string `json:"ttl,omitempty"`
+
+	// API requests token for REST API instead of session token for the web
+	// interface, session tokens can't change anything.
+	API bool `json:"api,omitempty"`
+}
+
+type ResponseIssueToken struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+type RequestListAuditRecords struct {
+	Signature *signature.Signature `json:"signature"`
+	Signer    string               `json:"signer,omitempty"`
+	Package   string               `json:"package,omitempty"`
+	Limit     int                  `json:"limit,omitempty"`
+}
+
+type ResponseListAuditRecords struct {
+	Records []AuditRecord `json:"records"`
+}
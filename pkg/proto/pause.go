@@ -0,0 +1,11 @@
+package proto
+
+import "time"
+
+// Pause describes who paused the build queue or a package and why, paused
+// packages are not dispatched until resumed.
+type Pause struct {
+	By     string    `bson:"by" json:"by"`
+	Since  time.Time `bson:"since" json:"since"`
+	Reason string    `bson:"reason" json:"reason,omitempty"`
+}
@@ -0,0 +1,33 @@
+package proto
+
+import "time"
+
+// QueueItem is a running build or a package waiting for a build, in order
+// the packages are dispatched by the processor.
+type QueueItem struct {
+	Package  string `json:"package"`
+	Status   string `json:"status"`
+	Priority int    `json:"priority"`
+	Class    string `json:"class,omitempty"`
+
+	// Instance runs the build, it's estimated for waiting builds as well as
+	// Start.
+	Instance string `json:"instance"`
+
+	// Effective is the priority raised by waiting time, packages are
+	// dispatched by it.
+	Effective int `json:"effective"`
+
+	// Waiting is how long the package has been due for a build, it's zero
+	// for running builds.
+	Waiting time.Duration `json:"waiting"`
+
+	// Duration is average duration of recent successful builds of the
+	// package, zero if it has never been built.
+	Duration time.Duration `json:"duration"`
+
+	// Start is estimated unless the build is running, Finish is always
+	// estimated.
+	Start  time.Time `json:"start"`
+	Finish time.Time `json:"finish"`
+}
@@ -0,0 +1,30 @@
+package proto
+
+import "time"
+
+// Stage is a version of a package published to a testing repository and
+// waiting there for promotion to the stable repository.
+type Stage struct {
+	Package string `bson:"package" json:"package"`
+
+	// Repo is the testing repository, Target is where the package is
+	// promoted to.
+	Repo   string `bson:"repo" json:"repo"`
+	Target string `bson:"target" json:"target"`
+
+	Version   string    `bson:"version" json:"version"`
+	Published time.Time `bson:"published" json:"published"`
+	Problems  []string  `bson:"problems" json:"problems,omitempty"`
+
+	// Requested is set by hand promotion, RequestedVersion is empty if the
+	// staged version is requested.
+	Requested        bool   `bson:"requested" json:"requested,omitempty"`
+	RequestedVersion string `bson:"requested_version" json:"requested_version,omitempty"`
+
+	Promoted   string    `bson:"promoted" json:"promoted,omitempty"`
+	PromotedAt time.Time `bson:"promoted_at" json:"promoted_at,omitempty"`
+
+	// Failed is the version which promotion has failed with Error.
+	Failed string `bson:"failed" json:"failed,omitempty"`
+	Error  string `bson:"error" json:"error,omitempty"`
+}
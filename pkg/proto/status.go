@@ -18,6 +18,7 @@ var (
 	BuildStatusFailure    BuildStatus = buildStatus{"failure"}
 	BuildStatusSuccess    BuildStatus = buildStatus{"success"}
 	BuildStatusQueued     BuildStatus = buildStatus{"queued"}
+	BuildStatusCancelled  BuildStatus = buildStatus{"cancelled"}
 )
 
 func (status buildStatus) MarshalJSON() ([]byte, error) {
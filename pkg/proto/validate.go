@@ -1,11 +1,60 @@
 package proto
 
-import "regexp"
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
 
 var (
-	rePkgName = regexp.MustCompile(`^[a-z0-9][a-z0-9@\._+-]+$`)
+	rePkgName  = regexp.MustCompile(`^[a-z0-9][a-z0-9@\._+-]+$`)
+	reRepoName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	reEnvName  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// reScpURL matches scp-like syntax of git: user@host:path
+	reScpURL = regexp.MustCompile(`^[a-zA-Z0-9._-]+@[a-zA-Z0-9.-]+:[^/].*$`)
 )
 
+// cloneSchemes are URL schemes supported by git clone.
+var cloneSchemes = []string{"https", "http", "git", "ssh"}
+
+// ReservedEnvPrefix is a prefix of environment variables which are set by
+// aurora itself in build containers.
+const ReservedEnvPrefix = "AURORA_"
+
 func IsValidPackageName(name string) bool {
 	return rePkgName.MatchString(name)
 }
+
+// IsValidRepositoryName reports whether name can be used as a pacman
+// repository name and as a path segment it is served at.
+func IsValidRepositoryName(name string) bool {
+	return reRepoName.MatchString(name)
+}
+
+// IsValidCloneURL reports whether url can be passed to git clone.
+func IsValidCloneURL(value string) bool {
+	if reScpURL.MatchString(value) {
+		return true
+	}
+
+	uri, err := url.Parse(value)
+	if err != nil || uri.Host == "" || uri.Path == "" {
+		return false
+	}
+
+	for _, scheme := range cloneSchemes {
+		if uri.Scheme == scheme {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsValidEnvName reports whether name can be used as an environment
+// variable of a build container.
+func IsValidEnvName(name string) bool {
+	return reEnvName.MatchString(name) &&
+		!strings.HasPrefix(strings.ToUpper(name), ReservedEnvPrefix)
+}
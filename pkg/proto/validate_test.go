@@ -34,3 +34,82 @@ func TestIsValidPackageName(t *testing.T) {
 		test.Equal(testcase.Valid, actual, testcase.Input)
 	}
 }
+
+func TestIsValidRepositoryName(t *testing.T) {
+	test := assert.New(t)
+
+	testcases := []struct {
+		Input string
+		Valid bool
+	}{
+		{"aurora", true},
+		{"aurora-testing", true},
+		{"infra_2", true},
+		{"a", true},
+		{"", false},
+		{"-aurora", false},
+		{"Aurora", false},
+		{"aurora.testing", false},
+		{"aurora/testing", false},
+		{"..", false},
+	}
+
+	for _, testcase := range testcases {
+		actual := IsValidRepositoryName(testcase.Input)
+
+		test.Equal(testcase.Valid, actual, testcase.Input)
+	}
+}
+
+func TestIsValidCloneURL(t *testing.T) {
+	test := assert.New(t)
+
+	testcases := []struct {
+		Input string
+		Valid bool
+	}{
+		{"https://aur.archlinux.org/yay.git", true},
+		{"http://git.example.com/pkg", true},
+		{"git://git.example.com/pkg.git", true},
+		{"ssh://git@github.com/user/pkg.git", true},
+		{"git@github.com:user/pkg.git", true},
+		{"", false},
+		{"yay", false},
+		{"/srv/git/pkg.git", false},
+		{"file:///srv/git/pkg.git", false},
+		{"ftp://example.com/pkg.git", false},
+		{"https://", false},
+		{"git@github.com:/pkg.git", false},
+	}
+
+	for _, testcase := range testcases {
+		actual := IsValidCloneURL(testcase.Input)
+
+		test.Equal(testcase.Valid, actual, testcase.Input)
+	}
+}
+
+func TestIsValidEnvName(t *testing.T) {
+	test := assert.New(t)
+
+	testcases := []struct {
+		Input string
+		Valid bool
+	}{
+		{"MAKEFLAGS", true},
+		{"_private", true},
+		{"GOFLAGS_2", true},
+		{"", false},
+		{"2FAST", false},
+		{"A-B", false},
+		{"A=B", false},
+		{"AURORA_PACKAGE", false},
+		{"aurora_clone_url", false},
+	}
+
+	for _, testcase := range testcases {
+		actual := IsValidEnvName(testcase.Input)
+
+		test.Equal(testcase.Valid, actual, testcase.Input)
+	}
+}
@@ -0,0 +1,131 @@
+package rpc
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/kovetskiy/aurora/pkg/audit"
+	"github.com/kovetskiy/aurora/pkg/pause"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/signature"
+	"github.com/reconquest/karma-go"
+)
+
+// AdminService controls the build queue as a whole, e.g. pauses it for
+// maintenance.
+type AdminService struct {
+	queue    *pause.Queue
+	packages *mgo.Collection
+	auth     *AuthService
+	trail    *audit.Trail
+
+	// admins are names of signers allowed to control the queue, every
+	// authorized signer is allowed if it's empty
+	admins []string
+}
+
+func NewAdminService(
+	queue *pause.Queue,
+	packages *mgo.Collection,
+	auth *AuthService,
+	trail *audit.Trail,
+	admins []string,
+) *AdminService {
+	return &AdminService{
+		queue:    queue,
+		packages: packages,
+		auth:     auth,
+		trail:    trail,
+		admins:   admins,
+	}
+}
+
+// PauseQueue stops processors from dispatching new builds until the queue
+// is resumed, running builds are not affected.
+func (service *AdminService) PauseQueue(
+	source *http.Request,
+	request *proto.RequestPauseQueue,
+	response *proto.ResponsePauseQueue,
+) error {
+	signer := service.auth.Authenticate(source, request.Signature)
+	if signer == nil || !service.isAdmin(signer) {
+		return ErrorUnauthorized
+	}
+
+	var err error
+	if request.Paused {
+		err = service.queue.Pause(proto.Pause{
+			By:     signer.Name,
+			Since:  time.Now(),
+			Reason: request.Reason,
+		})
+	} else {
+		err = service.queue.Resume()
+	}
+
+	return record(
+		service.trail, source, signer, "AdminService.PauseQueue",
+		map[string]interface{}{
+			"paused": request.Paused,
+			"reason": request.Reason,
+		},
+		err,
+	)
+}
+
+// GetStatus returns paused state of the queue, running builds and
+// processors which have not stopped for the pause yet.
+func (service *AdminService) GetStatus(
+	source *http.Request,
+	request *proto.RequestGetStatus,
+	response *proto.ResponseGetStatus,
+) error {
+	signer, err := service.auth.Authorize(
+		source,
+		request.Signature,
+		"AdminService.GetStatus",
+	)
+	if err != nil {
+		return err
+	}
+
+	response.Pause, err = service.queue.Get()
+	if err != nil {
+		return err
+	}
+
+	if response.Pause != nil {
+		response.Dispatching, err = service.queue.GetDispatching(
+			*response.Pause,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	response.Running = []string{}
+
+	err = service.packages.Find(
+		VisibleTo(signer, bson.M{
+			"status": proto.BuildStatusProcessing.String(),
+		}),
+	).Distinct("name", &response.Running)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to find running builds",
+		)
+	}
+
+	return nil
+}
+
+func (service *AdminService) isAdmin(signer *signature.Signer) bool {
+	if len(service.admins) == 0 {
+		return true
+	}
+
+	return contains(service.admins, signer.Name)
+}
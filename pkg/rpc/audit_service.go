@@ -0,0 +1,91 @@
+package rpc
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/kovetskiy/aurora/pkg/audit"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/signature"
+	"github.com/reconquest/karma-go"
+)
+
+// AuditService provides access to the audit trail of actions performed
+// against aurora.
+type AuditService struct {
+	trail *audit.Trail
+	auth  *AuthService
+}
+
+func NewAuditService(trail *audit.Trail, auth *AuthService) *AuditService {
+	return &AuditService{
+		trail: trail,
+		auth:  auth,
+	}
+}
+
+func (service *AuditService) ListRecords(
+	source *http.Request,
+	request *proto.RequestListAuditRecords,
+	response *proto.ResponseListAuditRecords,
+) error {
+	signer := service.auth.Authenticate(source, request.Signature)
+	if signer == nil {
+		return ErrorUnauthorized
+	}
+
+	records, err := service.trail.Find(
+		request.Signer,
+		request.Package,
+		request.Limit,
+	)
+	if err != nil {
+		return err
+	}
+
+	response.Records = records
+
+	return nil
+}
+
+// record records the action performed by the signer into the audit trail
+// and returns the result of the action as is.
+func record(
+	trail *audit.Trail,
+	source *http.Request,
+	signer *signature.Signer,
+	method string,
+	params map[string]interface{},
+	result error,
+) error {
+	err := trail.Record(
+		signer.String(),
+		method,
+		getSourceAddress(source),
+		params,
+		result,
+	)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to record action into audit trail",
+		)
+	}
+
+	return result
+}
+
+// getSourceAddress returns IP address of the caller, RemoteAddr is already
+// replaced with X-Real-IP/X-Forwarded-For by the middleware if any.
+func getSourceAddress(source *http.Request) string {
+	if source == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(source.RemoteAddr)
+	if err != nil {
+		return source.RemoteAddr
+	}
+
+	return host
+}
@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"context"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -8,6 +9,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/kovetskiy/aurora/pkg/proto"
 	"github.com/kovetskiy/aurora/pkg/signature"
@@ -19,11 +22,60 @@ type rsaKey struct {
 	key    *rsa.PublicKey
 }
 
+// ReadOnlyMethods lists RPC methods which can be opened for anonymous
+// (unsigned) callers.
+var ReadOnlyMethods = []string{
+	"PackageService.ListPackages",
+	"PackageService.GetPackage",
+	"PackageService.GetLogs",
+	"PackageService.GetBus",
+	"PackageService.ListBuilds",
+	"PackageService.GetQueue",
+	"AdminService.GetStatus",
+}
+
+const (
+	// SessionTokenTTL is how long the token returned by IssueToken can be
+	// used if no TTL is requested.
+	SessionTokenTTL = 12 * time.Hour
+
+	// MaxSessionTokenTTL limits TTL which can be requested for the token,
+	// e.g. for using in CI.
+	MaxSessionTokenTTL = 30 * 24 * time.Hour
+
+	// session tokens are passed in URLs (sign in link, feed), so they only
+	// allow browsing the web interface, API tokens are required for REST
+	// API and issued only explicitly
+	sessionTokenPrefix = "session:"
+	apiTokenPrefix     = "api:"
+)
+
+type signerContextKey struct{}
+
 type AuthService struct {
-	keys []rsaKey
+	keys      []rsaKey
+	anonymous map[string]struct{}
+	tokens    *Tokens
 }
 
-func NewAuthService(authorizedKeysDir string) (*AuthService, error) {
+func NewAuthService(
+	authorizedKeysDir string,
+	anonymousMethods []string,
+	tokens *Tokens,
+) (*AuthService, error) {
+	anonymous := map[string]struct{}{}
+	for _, method := range anonymousMethods {
+		if !isReadOnlyMethod(method) {
+			return nil, fmt.Errorf(
+				"method %q can't be allowed for anonymous callers, "+
+					"only read-only methods are allowed: %v",
+				method, ReadOnlyMethods,
+			)
+		}
+
+		anonymous[method] = struct{}{}
+	}
+
 	paths, err := filepath.Glob(filepath.Join(authorizedKeysDir, "*"))
 	if err != nil {
 		return nil, karma.Format(
@@ -64,7 +116,9 @@ func NewAuthService(authorizedKeysDir string) (*AuthService, error) {
 	}
 
 	return &AuthService{
-		keys: keys,
+		keys:      keys,
+		anonymous: anonymous,
+		tokens:    tokens,
 	}, nil
 }
 
@@ -73,21 +127,98 @@ func (service *AuthService) WhoAmI(
 	request *proto.RequestWhoAmI,
 	response *proto.ResponseWhoAmI,
 ) error {
-	signature := request.Signature
-	if signature == nil {
+	signer := service.Authenticate(source, request.Signature)
+	if signer == nil {
 		return nil
 	}
 
-	signer := service.Verify(signature)
+	response.Name = signer.Name
+
+	return nil
+}
+
+// IssueToken returns session token for the signer which is used for
+// browsing the web interface or, if API is requested, token which is used
+// as bearer token for REST API where requests can't be signed.
+func (service *AuthService) IssueToken(
+	source *http.Request,
+	request *proto.RequestIssueToken,
+	response *proto.ResponseIssueToken,
+) error {
+	signer := service.Authenticate(source, request.Signature)
 	if signer == nil {
+		return ErrorUnauthorized
+	}
+
+	ttl := SessionTokenTTL
+	if request.TTL != "" {
+		var err error
+
+		ttl, err = time.ParseDuration(request.TTL)
+		if err != nil {
+			return karma.Format(err, "invalid ttl: %s", request.TTL)
+		}
+
+		if ttl <= 0 || ttl > MaxSessionTokenTTL {
+			return fmt.Errorf(
+				"ttl should be positive and not greater than %s",
+				MaxSessionTokenTTL,
+			)
+		}
+	}
+
+	prefix := sessionTokenPrefix
+	if request.API {
+		prefix = apiTokenPrefix
+	}
+
+	response.Token = service.tokens.Issue(prefix+signer.Name, ttl)
+	response.Expires = time.Now().Add(ttl)
+
+	return nil
+}
+
+// VerifySession returns signer of the session token issued by IssueToken,
+// nil is returned if the token is invalid, it's an API token or the key of
+// the signer has been removed since.
+func (service *AuthService) VerifySession(token string) *signature.Signer {
+	return service.verifyToken(token, sessionTokenPrefix)
+}
+
+// VerifyAPIToken returns signer of the API token issued by IssueToken, nil
+// is returned if the token is invalid, it's a session token or the key of
+// the signer has been removed since.
+func (service *AuthService) VerifyAPIToken(token string) *signature.Signer {
+	return service.verifyToken(token, apiTokenPrefix)
+}
+
+func (service *AuthService) verifyToken(
+	token string,
+	prefix string,
+) *signature.Signer {
+	subject, err := service.tokens.Verify(token)
+	if err != nil || !strings.HasPrefix(subject, prefix) {
 		return nil
 	}
 
-	response.Name = signer.Name
+	name := strings.TrimPrefix(subject, prefix)
+	for _, key := range service.keys {
+		if key.signer.Name == name {
+			return key.signer
+		}
+	}
 
 	return nil
 }
 
+// AllowsAnonymous returns true if specified method can be called without
+// signature.
+func (service *AuthService) AllowsAnonymous(method string) bool {
+	_, ok := service.anonymous[method]
+
+	return ok
+}
+
 func (service *AuthService) Verify(signature *signature.Signature) *signature.Signer {
 	if signature == nil {
 		return nil
@@ -101,3 +232,73 @@ func (service *AuthService) Verify(signature *signature.Signature) *signature.Si
 
 	return nil
 }
+
+// VerifyRequest checks signature passed in HTTP header of the request.
+func (service *AuthService) VerifyRequest(source *http.Request) *signature.Signer {
+	header := source.Header.Get(signature.Header)
+	if header == "" {
+		return nil
+	}
+
+	sign, err := signature.Decode(header)
+	if err != nil {
+		return nil
+	}
+
+	return service.Verify(sign)
+}
+
+// WithSigner returns copy of the request which is already authenticated as
+// the signer, it's used by transports that can't pass signature in the
+// request body, e.g. REST API.
+func WithSigner(source *http.Request, signer *signature.Signer) *http.Request {
+	return source.WithContext(
+		context.WithValue(source.Context(), signerContextKey{}, signer),
+	)
+}
+
+// Authenticate returns signer of the request authenticated by the transport
+// (see WithSigner) or signer of the signature.
+func (service *AuthService) Authenticate(
+	source *http.Request,
+	sign *signature.Signature,
+) *signature.Signer {
+	if source != nil {
+		signer, ok := source.Context().Value(signerContextKey{}).(*signature.Signer)
+		if ok && signer != nil {
+			return signer
+		}
+	}
+
+	return service.Verify(sign)
+}
+
+// Authorize returns signer of the request. If the request is not signed by
+// any of authorized keys, but specified method is allowed for anonymous
+// callers, nil signer is returned without error.
+func (service *AuthService) Authorize(
+	source *http.Request,
+	signature *signature.Signature,
+	method string,
+) (*signature.Signer, error) {
+	signer := service.Authenticate(source, signature)
+	if signer != nil {
+		return signer, nil
+	}
+
+	if service.AllowsAnonymous(method) {
+		return nil, nil
+	}
+
+	return nil, ErrorUnauthorized
+}
+
+func isReadOnlyMethod(method string) bool {
+	for _, readOnly := range ReadOnlyMethods {
+		if method == readOnly {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,20 +2,39 @@ package rpc
 
 import (
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
+	"github.com/kovetskiy/aurora/pkg/audit"
+	"github.com/kovetskiy/aurora/pkg/bus"
+	"github.com/kovetskiy/aurora/pkg/history"
 	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/schedule"
+	"github.com/kovetskiy/aurora/pkg/signature"
+	"github.com/kovetskiy/aurora/pkg/staging"
 	"github.com/reconquest/karma-go"
 )
 
-var ErrorUnauthorized = errors.New("you are not authorized to perform this action")
+var (
+	ErrorUnauthorized       = errors.New("you are not authorized to perform this action")
+	ErrorNoSuchPackage      = errors.New("no such package")
+	ErrorInvalidPackageName = errors.New("invalid package name")
+	ErrorPackageIsBuilding  = errors.New("package is being built now")
+	ErrorInvalidVersion     = errors.New("invalid version")
+	ErrorNoPreviousBuild    = errors.New("no previous successful build to roll back to")
+	ErrorInvalidSchedule    = errors.New("invalid schedule")
+)
+
+// BusTokenTTL is how long the token returned by GetBus can be used to open
+// the stream.
+const BusTokenTTL = time.Minute
 
 // PackageService handles all interactions with packages, including:
 //
@@ -23,28 +42,51 @@ var ErrorUnauthorized = errors.New("you are not authorized to perform this actio
 // - retrieving list of packages
 // - retrieving info about a package
 // - retrieving logs after build
+// - retrieving history of builds
 // - watching logs from bus
+// - scheduling a rebuild
+// - promoting packages from testing repositories
+// - pinning, freezing and rolling back packages
+// - changing options of packages and inspecting the queue
 //
 // Should be splitted into several services in order to decrease
 // responsibilities.
 type PackageService struct {
 	collection *mgo.Collection
+	builds     *history.History
 	auth       *AuthService
+	trail      *audit.Trail
+	tokens     *Tokens
+	notifier   proto.Notifier
 	logsDir    string
-	instance   string
+	repos      Repositories
+	staging    *staging.Staging
+	processor  schedule.Processor
 }
 
 func NewPackageService(
 	collection *mgo.Collection,
+	builds *history.History,
 	auth *AuthService,
+	trail *audit.Trail,
+	tokens *Tokens,
+	notifier proto.Notifier,
 	logsDir string,
-	instance string,
+	repos Repositories,
+	stages *staging.Staging,
+	processor schedule.Processor,
 ) *PackageService {
 	return &PackageService{
 		collection: collection,
+		builds:     builds,
 		logsDir:    logsDir,
+		repos:      repos,
+		staging:    stages,
+		processor:  processor,
 		auth:       auth,
-		instance:   instance,
+		trail:      trail,
+		tokens:     tokens,
+		notifier:   notifier,
 	}
 }
 
@@ -53,12 +95,48 @@ func (service *PackageService) ListPackages(
 	request *proto.RequestListPackages,
 	response *proto.ResponseListPackages,
 ) error {
-	signer := service.auth.Verify(request.Signature)
-	if signer == nil {
-		return ErrorUnauthorized
+	signer, err := service.auth.Authorize(
+		source,
+		request.Signature,
+		"PackageService.ListPackages",
+	)
+	if err != nil {
+		return err
+	}
+
+	err = service.collection.Find(
+		VisibleTo(signer, bson.M{}),
+	).All(&response.Packages)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to find packages in database",
+		)
+	}
+
+	return nil
+}
+
+// GetQueue returns running builds and packages waiting for a build in order
+// they are dispatched with estimated start and finish.
+func (service *PackageService) GetQueue(
+	source *http.Request,
+	request *proto.RequestGetQueue,
+	response *proto.ResponseGetQueue,
+) error {
+	signer, err := service.auth.Authorize(
+		source,
+		request.Signature,
+		"PackageService.GetQueue",
+	)
+	if err != nil {
+		return err
 	}
 
-	err := service.collection.Find(bson.M{}).All(&response.Packages)
+	// private packages are hidden from anonymous callers but still take
+	// threads, so they are estimated as well
+	var pkgs []proto.Package
+	err = service.collection.Find(bson.M{}).Sort("-priority").All(&pkgs)
 	if err != nil {
 		return karma.Format(
 			err,
@@ -66,6 +144,27 @@ func (service *PackageService) ListPackages(
 		)
 	}
 
+	durations, err := service.builds.FindDurations(0)
+	if err != nil {
+		return err
+	}
+
+	private := map[string]bool{}
+	for _, pkg := range pkgs {
+		private[pkg.Name] = pkg.Private
+	}
+
+	response.Queue = []proto.QueueItem{}
+	for _, item := range schedule.Plan(
+		pkgs, durations, service.processor, time.Now(),
+	) {
+		if signer == nil && private[item.Package] {
+			continue
+		}
+
+		response.Queue = append(response.Queue, item)
+	}
+
 	return nil
 }
 
@@ -74,13 +173,17 @@ func (service *PackageService) GetPackage(
 	request *proto.RequestGetPackage,
 	response *proto.ResponseGetPackage,
 ) error {
-	signer := service.auth.Verify(request.Signature)
-	if signer == nil {
-		return ErrorUnauthorized
+	signer, err := service.auth.Authorize(
+		source,
+		request.Signature,
+		"PackageService.GetPackage",
+	)
+	if err != nil {
+		return err
 	}
 
-	err := service.collection.Find(
-		bson.M{"name": request.Name},
+	err = service.collection.Find(
+		VisibleTo(signer, bson.M{"name": request.Name}),
 	).One(&response.Package)
 	if err == mgo.ErrNotFound {
 		response.Package = nil
@@ -93,6 +196,11 @@ func (service *PackageService) GetPackage(
 		)
 	}
 
+	response.Stages, err = service.staging.Find(request.Name)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -101,17 +209,18 @@ func (service *PackageService) GetLogs(
 	request *proto.RequestGetLogs,
 	response *proto.ResponseGetLogs,
 ) error {
-	signer := service.auth.Verify(request.Signature)
-	if signer == nil {
-		return ErrorUnauthorized
+	signer, err := service.auth.Authorize(
+		source,
+		request.Signature,
+		"PackageService.GetLogs",
+	)
+	if err != nil {
+		return err
 	}
 
-	var pkg proto.Package
-	err := service.collection.Find(
-		bson.M{"name": request.Name},
-	).One(&pkg)
-	if err == mgo.ErrNotFound {
-		return errors.New("no such package")
+	pkg, err := service.findPackage(signer, request.Name)
+	if err != nil {
+		return err
 	}
 
 	if !proto.IsValidPackageName(pkg.Name) {
@@ -142,60 +251,169 @@ func (service *PackageService) GetBus(
 	request *proto.RequestGetBus,
 	response *proto.ResponseGetBus,
 ) error {
-	signer := service.auth.Verify(request.Signature)
-	if signer == nil {
-		return ErrorUnauthorized
+	signer, err := service.auth.Authorize(
+		source,
+		request.Signature,
+		"PackageService.GetBus",
+	)
+	if err != nil {
+		return err
 	}
 
-	var pkg proto.Package
-	err := service.collection.Find(
-		bson.M{"name": request.Name},
-	).One(&pkg)
-	if err == mgo.ErrNotFound {
-		return errors.New("no such package")
+	// the stream is relayed by the web server from the processors, so the
+	// client needs only the public address
+	if request.All {
+		response.Stream = "/bus/?" + url.Values{
+			"all": {"1"},
+			"token": {service.tokens.Issue(
+				BusTokenSubject(bus.TopicEvents, signer == nil),
+				BusTokenTTL,
+			)},
+		}.Encode()
+
+		return nil
 	}
 
-	instance := pkg.Instance
-	if instance == "" {
-		instance = service.instance
+	pkg, err := service.findPackage(signer, request.Name)
+	if err != nil {
+		return err
 	}
 
-	// here can be complex logic with retrieving address of processor
-	address := fmt.Sprintf(
-		"ws://%s:%d/?package=%s",
-		instance,
-		proto.DefaultBusServerPort,
-		request.Name,
+	response.Stream = "/bus/?" + url.Values{
+		"package": {pkg.Name},
+		"token": {service.tokens.Issue(
+			BusTokenSubject(pkg.Name, signer == nil),
+			BusTokenTTL,
+		)},
+	}.Encode()
+
+	return nil
+}
+
+func (service *PackageService) ListBuilds(
+	source *http.Request,
+	request *proto.RequestListBuilds,
+	response *proto.ResponseListBuilds,
+) error {
+	signer, err := service.auth.Authorize(
+		source,
+		request.Signature,
+		"PackageService.ListBuilds",
 	)
+	if err != nil {
+		return err
+	}
+
+	pkg, err := service.findPackage(signer, request.Name)
+	if err != nil {
+		return err
+	}
 
-	response.Stream = address
+	response.Builds, err = service.builds.Find(pkg.Name, request.Limit)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// BusTokenSubject returns subject of the token which allows to watch
+// specified topic of the bus, public tokens are given to anonymous callers
+// and don't allow to see private packages.
+func BusTokenSubject(topic string, public bool) string {
+	subject := "bus:" + topic
+	if public {
+		subject += ":public"
+	}
+
+	return subject
+}
+
+// ParseBusTokenSubject is the reverse of BusTokenSubject.
+func ParseBusTokenSubject(subject string) (topic string, public bool, err error) {
+	chunks := strings.Split(subject, ":")
+	if len(chunks) < 2 || len(chunks) > 3 || chunks[0] != "bus" {
+		return "", false, ErrorInvalidToken
+	}
+
+	if len(chunks) == 3 {
+		if chunks[2] != "public" {
+			return "", false, ErrorInvalidToken
+		}
+
+		public = true
+	}
+
+	return chunks[1], public, nil
+}
+
 func (service *PackageService) AddPackage(
 	source *http.Request,
 	request *proto.RequestAddPackage,
 	response *proto.ResponseAddPackage,
 ) error {
-	signer := service.auth.Verify(request.Signature)
+	signer := service.auth.Authenticate(source, request.Signature)
 	if signer == nil {
 		return ErrorUnauthorized
 	}
 
-	if !proto.IsValidPackageName(request.Name) {
-		return errors.New("invalid package name")
-	}
+	err := service.addPackage(request, signer.Name)
 
-	err := service.collection.Insert(
-		proto.Package{
-			Name:   request.Name,
-			Status: proto.BuildStatusQueued.String(),
-			Date:   time.Now(),
+	return service.audit(
+		source, signer, "PackageService.AddPackage",
+		map[string]interface{}{
+			"name":      request.Name,
+			"clone_url": request.CloneURL,
+			"private":   request.Private,
+			"priority":  request.Priority,
+			"repos":     request.Repos,
+			"schedule":  request.Schedule,
 		},
+		err,
 	)
+}
+
+func (service *PackageService) addPackage(
+	request *proto.RequestAddPackage,
+	owner string,
+) error {
+	if !proto.IsValidPackageName(request.Name) {
+		return ErrorInvalidPackageName
+	}
+
+	repos, err := service.repos.Resolve(request.Repos)
+	if err != nil {
+		return err
+	}
+
+	rebuild, err := schedule.Parse(request.Schedule)
+	if err != nil {
+		return ErrorInvalidSchedule
+	}
+
+	if request.CloneURL != "" && !proto.IsValidCloneURL(request.CloneURL) {
+		return ErrorInvalidCloneURL
+	}
+
+	now := time.Now()
+
+	pkg := proto.Package{
+		Name:     request.Name,
+		CloneURL: request.CloneURL,
+		Status:   proto.BuildStatusQueued.String(),
+		Date:     now,
+		Next:     now,
+		Priority: request.Priority,
+		Private:  request.Private,
+		Owner:    owner,
+		Repos:    repos,
+		Schedule: rebuild,
+	}
+
+	err = service.collection.Insert(pkg)
 
 	if err == nil {
+		service.notifier.Notify(proto.NewEvent(proto.EventPackageAdded, pkg))
 		return nil
 	} else if mgo.IsDup(err) {
 		return nil
@@ -209,14 +427,444 @@ func (service *PackageService) RemovePackage(
 	request *proto.RequestRemovePackage,
 	response *proto.ResponseRemovePackage,
 ) error {
-	signer := service.auth.Verify(request.Signature)
+	signer := service.auth.Authenticate(source, request.Signature)
 	if signer == nil {
 		return ErrorUnauthorized
 	}
 
-	err := service.collection.Remove(
+	var pkg proto.Package
+
+	_, err := service.collection.Find(
 		bson.M{"name": request.Name},
+	).Apply(mgo.Change{Remove: true}, &pkg)
+	if err == nil {
+		service.notifier.Notify(
+			proto.NewEvent(proto.EventPackageRemoved, pkg),
+		)
+	} else if err == mgo.ErrNotFound {
+		err = ErrorNoSuchPackage
+	}
+
+	return service.audit(
+		source, signer, "PackageService.RemovePackage",
+		map[string]interface{}{
+			"name": request.Name,
+		},
+		err,
+	)
+}
+
+// UpdatePackage changes options of the package, it can be done while the
+// package is being built and takes effect since the next build.
+func (service *PackageService) UpdatePackage(
+	source *http.Request,
+	request *proto.RequestUpdatePackage,
+	response *proto.ResponseUpdatePackage,
+) error {
+	signer := service.auth.Authenticate(source, request.Signature)
+	if signer == nil {
+		return ErrorUnauthorized
+	}
+
+	update, err := service.getPackageUpdate(request)
+	if err == nil {
+		err = service.updatePackage(request.Name, update)
+	}
+
+	return service.audit(
+		source, signer, "PackageService.UpdatePackage",
+		map[string]interface{}{
+			"name":   request.Name,
+			"update": update,
+		},
+		err,
+	)
+}
+
+func (service *PackageService) updatePackage(name string, update bson.M) error {
+	if len(update) == 0 {
+		return nil
+	}
+
+	err := service.collection.Update(bson.M{"name": name}, update)
+	if err == mgo.ErrNotFound {
+		return ErrorNoSuchPackage
+	}
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to update package",
+		)
+	}
+
+	// schedule is evaluated by the processor on every poll, but the next
+	// build time shown to users has to be updated here
+	var pkg proto.Package
+	err = service.collection.Find(bson.M{"name": name}).One(&pkg)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to find package in database",
+		)
+	}
+
+	err = service.collection.Update(
+		bson.M{"name": name},
+		bson.M{"$set": bson.M{
+			"next": schedule.Next(pkg, service.processor.Defaults),
+		}},
+	)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to update next build time of package",
+		)
+	}
+
+	return nil
+}
+
+// RebuildPackage queues the package for building right away regardless
+// of the time passed since the last build.
+func (service *PackageService) RebuildPackage(
+	source *http.Request,
+	request *proto.RequestRebuildPackage,
+	response *proto.ResponseRebuildPackage,
+) error {
+	signer := service.auth.Authenticate(source, request.Signature)
+	if signer == nil {
+		return ErrorUnauthorized
+	}
+
+	err := service.rebuildPackage(request.Name)
+
+	return service.audit(
+		source, signer, "PackageService.RebuildPackage",
+		map[string]interface{}{
+			"name": request.Name,
+		},
+		err,
+	)
+}
+
+// rebuildPackage queues the package and lifts its quarantine, so failures
+// are counted from scratch.
+func (service *PackageService) rebuildPackage(name string) error {
+	return service.updateIdlePackage(
+		name,
+		bson.M{
+			"$set": bson.M{
+				"status": proto.BuildStatusQueued.String(),
+				"next":   time.Now(),
+			},
+			"$unset": bson.M{
+				"failures":    "",
+				"quarantined": "",
+			},
+		},
+	)
+}
+
+// updateIdlePackage updates the package unless it's being built, so the
+// update is not overwritten when the build finishes.
+func (service *PackageService) updateIdlePackage(name string, update bson.M) error {
+	err := service.collection.Update(
+		bson.M{
+			"name":   name,
+			"status": bson.M{"$ne": proto.BuildStatusProcessing.String()},
+		},
+		update,
+	)
+	if err == mgo.ErrNotFound {
+		count, err := service.collection.Find(bson.M{"name": name}).Count()
+		if err != nil {
+			return karma.Format(
+				err,
+				"unable to find package in database",
+			)
+		}
+
+		if count == 0 {
+			return ErrorNoSuchPackage
+		}
+
+		return ErrorPackageIsBuilding
+	}
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to update package",
+		)
+	}
+
+	return nil
+}
+
+// PinPackage republishes archived build of specified version and freezes
+// the package, so it's not replaced by new builds.
+func (service *PackageService) PinPackage(
+	source *http.Request,
+	request *proto.RequestPinPackage,
+	response *proto.ResponsePinPackage,
+) error {
+	signer := service.auth.Authenticate(source, request.Signature)
+	if signer == nil {
+		return ErrorUnauthorized
+	}
+
+	var err error
+	if request.Version == "" {
+		err = ErrorInvalidVersion
+	} else {
+		err = service.pinPackage(request.Name, proto.Pin{Version: request.Version})
+	}
+
+	return service.audit(
+		source, signer, "PackageService.PinPackage",
+		map[string]interface{}{
+			"name":    request.Name,
+			"version": request.Version,
+		},
+		err,
+	)
+}
+
+// RollbackPackage republishes the previous successful build of the package
+// and freezes the package.
+func (service *PackageService) RollbackPackage(
+	source *http.Request,
+	request *proto.RequestRollbackPackage,
+	response *proto.ResponseRollbackPackage,
+) error {
+	signer := service.auth.Authenticate(source, request.Signature)
+	if signer == nil {
+		return ErrorUnauthorized
+	}
+
+	pin, err := service.getRollbackPin(signer, request.Name)
+	if err == nil {
+		err = service.pinPackage(request.Name, pin)
+		response.Version = pin.Version
+	}
+
+	return service.audit(
+		source, signer, "PackageService.RollbackPackage",
+		map[string]interface{}{
+			"name":    request.Name,
+			"version": pin.Version,
+			"archive": pin.Archive,
+		},
+		err,
+	)
+}
+
+func (service *PackageService) getRollbackPin(
+	signer *signature.Signer,
+	name string,
+) (proto.Pin, error) {
+	pkg, err := service.findPackage(signer, name)
+	if err != nil {
+		return proto.Pin{}, err
+	}
+
+	// rolling back pinned package goes further back
+	if pkg.Pin != nil && pkg.Pin.Archive != "" {
+		pkg.Archive = pkg.Pin.Archive
+		pkg.Version = pkg.Pin.Version
+	}
+
+	previous, ok, err := service.builds.FindPrevious(*pkg)
+	if err != nil {
+		return proto.Pin{}, err
+	}
+
+	if !ok {
+		return proto.Pin{}, ErrorNoPreviousBuild
+	}
+
+	return proto.Pin{
+		Version: previous.Version,
+		Archive: previous.Archive,
+	}, nil
+}
+
+func (service *PackageService) pinPackage(name string, pin proto.Pin) error {
+	pin.Archive = filepath.Base(pin.Archive)
+	if pin.Archive == "." {
+		pin.Archive = ""
+	}
+
+	pin.Pending = true
+
+	return service.updateIdlePackage(
+		name,
+		bson.M{
+			"$set": bson.M{
+				"frozen": true,
+				"pin":    pin,
+			},
+		},
+	)
+}
+
+// FreezePackage stops or resumes rebuilds of the package, resuming also
+// removes the pin.
+func (service *PackageService) FreezePackage(
+	source *http.Request,
+	request *proto.RequestFreezePackage,
+	response *proto.ResponseFreezePackage,
+) error {
+	signer := service.auth.Authenticate(source, request.Signature)
+	if signer == nil {
+		return ErrorUnauthorized
+	}
+
+	update := bson.M{"$set": bson.M{"frozen": true}}
+	if !request.Frozen {
+		update = bson.M{
+			"$set":   bson.M{"frozen": false},
+			"$unset": bson.M{"pin": ""},
+		}
+	}
+
+	err := service.updateIdlePackage(request.Name, update)
+
+	return service.audit(
+		source, signer, "PackageService.FreezePackage",
+		map[string]interface{}{
+			"name":   request.Name,
+			"frozen": request.Frozen,
+		},
+		err,
+	)
+}
+
+// PausePackage stops dispatching builds of the package until it's resumed,
+// running build is not affected.
+func (service *PackageService) PausePackage(
+	source *http.Request,
+	request *proto.RequestPausePackage,
+	response *proto.ResponsePausePackage,
+) error {
+	signer := service.auth.Authenticate(source, request.Signature)
+	if signer == nil {
+		return ErrorUnauthorized
+	}
+
+	update := bson.M{"$unset": bson.M{"paused": ""}}
+	if request.Paused {
+		update = bson.M{"$set": bson.M{"paused": proto.Pause{
+			By:     signer.Name,
+			Since:  time.Now(),
+			Reason: request.Reason,
+		}}}
+	}
+
+	err := service.updatePackage(request.Name, update)
+
+	return service.audit(
+		source, signer, "PackageService.PausePackage",
+		map[string]interface{}{
+			"name":   request.Name,
+			"paused": request.Paused,
+			"reason": request.Reason,
+		},
+		err,
+	)
+}
+
+// PromotePackage asks the processor to promote the package from testing
+// repositories, the staged version is promoted if no version is specified.
+func (service *PackageService) PromotePackage(
+	source *http.Request,
+	request *proto.RequestPromotePackage,
+	response *proto.ResponsePromotePackage,
+) error {
+	signer := service.auth.Authenticate(source, request.Signature)
+	if signer == nil {
+		return ErrorUnauthorized
+	}
+
+	err := service.staging.Request(request.Name, request.Version)
+
+	return service.audit(
+		source, signer, "PackageService.PromotePackage",
+		map[string]interface{}{
+			"name":    request.Name,
+			"version": request.Version,
+		},
+		err,
+	)
+}
+
+// ReportProblem records a problem with the staged version of the package,
+// such version is not promoted automatically after soak period.
+func (service *PackageService) ReportProblem(
+	source *http.Request,
+	request *proto.RequestReportProblem,
+	response *proto.ResponseReportProblem,
+) error {
+	signer := service.auth.Authenticate(source, request.Signature)
+	if signer == nil {
+		return ErrorUnauthorized
+	}
+
+	err := service.staging.Report(
+		request.Name,
+		signer.Name+": "+request.Problem,
 	)
 
-	return err
+	return service.audit(
+		source, signer, "PackageService.ReportProblem",
+		map[string]interface{}{
+			"name":    request.Name,
+			"problem": request.Problem,
+		},
+		err,
+	)
+}
+
+// findPackage returns the package if it's visible to the signer.
+func (service *PackageService) findPackage(
+	signer *signature.Signer,
+	name string,
+) (*proto.Package, error) {
+	var pkg proto.Package
+
+	err := service.collection.Find(
+		VisibleTo(signer, bson.M{"name": name}),
+	).One(&pkg)
+	if err == mgo.ErrNotFound {
+		return nil, ErrorNoSuchPackage
+	}
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to find package in database",
+		)
+	}
+
+	return &pkg, nil
+}
+
+// audit records the action performed by the signer into the audit trail and
+// returns the result of the action as is.
+func (service *PackageService) audit(
+	source *http.Request,
+	signer *signature.Signer,
+	method string,
+	params map[string]interface{},
+	result error,
+) error {
+	return record(service.trail, source, signer, method, params, result)
+}
+
+// VisibleTo limits given query to packages which can be seen by the signer,
+// anonymous callers (nil signer) can't see private packages.
+func VisibleTo(signer *signature.Signer, query bson.M) bson.M {
+	if signer == nil {
+		query["private"] = bson.M{"$ne": true}
+	}
+
+	return query
 }
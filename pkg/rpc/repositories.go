@@ -0,0 +1,51 @@
+package rpc
+
+import (
+	"errors"
+)
+
+var ErrorNoSuchRepository = errors.New("no such repository")
+
+// Repositories describes pacman repositories served by the daemon, packages
+// are published to Default repositories unless specified otherwise.
+type Repositories struct {
+	Available []string
+	Default   []string
+}
+
+// Resolve validates names of repositories requested for a package, empty
+// list resolves to default repositories.
+func (repos Repositories) Resolve(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return repos.Default, nil
+	}
+
+	resolved := []string{}
+	for _, name := range names {
+		if !repos.has(name) {
+			return nil, ErrorNoSuchRepository
+		}
+
+		if contains(resolved, name) {
+			continue
+		}
+
+		resolved = append(resolved, name)
+	}
+
+	return resolved, nil
+}
+
+func (repos Repositories) has(name string) bool {
+	return contains(repos.Available, name)
+}
+
+func contains(items []string, item string) bool {
+	for _, candidate := range items {
+		if candidate == item {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,97 @@
+package rpc
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reconquest/karma-go"
+)
+
+var ErrorInvalidToken = errors.New("token is invalid or expired")
+
+// Tokens issues short-lived tokens which can be used instead of signature
+// when it's not possible to sign a request, e.g. while opening websocket.
+type Tokens struct {
+	secret []byte
+}
+
+// NewTokens creates tokens signed by given secret, if secret is empty then
+// random one is used, so tokens will not survive restart.
+func NewTokens(secret string) (*Tokens, error) {
+	if secret != "" {
+		return &Tokens{secret: []byte(secret)}, nil
+	}
+
+	random := make([]byte, 32)
+
+	_, err := rand.Read(random)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to generate random secret for tokens",
+		)
+	}
+
+	return &Tokens{secret: random}, nil
+}
+
+// Issue returns token for the subject which is valid during ttl.
+func (tokens *Tokens) Issue(subject string, ttl time.Duration) string {
+	payload := subject + "|" + strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) +
+		"." +
+		base64.RawURLEncoding.EncodeToString(tokens.sign(payload))
+}
+
+// Verify checks that token is issued by us and not yet expired and returns
+// its subject.
+func (tokens *Tokens) Verify(token string) (string, error) {
+	chunks := strings.SplitN(token, ".", 2)
+	if len(chunks) != 2 {
+		return "", ErrorInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(chunks[0])
+	if err != nil {
+		return "", ErrorInvalidToken
+	}
+
+	sign, err := base64.RawURLEncoding.DecodeString(chunks[1])
+	if err != nil {
+		return "", ErrorInvalidToken
+	}
+
+	if !hmac.Equal(sign, tokens.sign(string(payload))) {
+		return "", ErrorInvalidToken
+	}
+
+	separator := strings.LastIndex(string(payload), "|")
+	if separator < 0 {
+		return "", ErrorInvalidToken
+	}
+
+	expires, err := strconv.ParseInt(string(payload[separator+1:]), 10, 64)
+	if err != nil {
+		return "", ErrorInvalidToken
+	}
+
+	if time.Now().Unix() > expires {
+		return "", ErrorInvalidToken
+	}
+
+	return string(payload[:separator]), nil
+}
+
+func (tokens *Tokens) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, tokens.secret)
+	mac.Write([]byte(payload))
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,127 @@
+package rpc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/aurora/pkg/schedule"
+)
+
+var (
+	ErrorInvalidCloneURL  = errors.New("invalid clone url")
+	ErrorInvalidTimeout   = errors.New("timeout should be at least 1m")
+	ErrorInvalidResources = errors.New("cpu should be positive and memory at least 6MB")
+	ErrorInvalidEnv       = errors.New("invalid or reserved environment variable name")
+	ErrorNoSuchClass      = errors.New("no such resource class")
+)
+
+const (
+	// MinTimeout is the shortest build timeout which can be set.
+	MinTimeout = time.Minute
+
+	// MinMemory is the least memory limit docker accepts.
+	MinMemory = 6 * 1024 * 1024
+)
+
+// getPackageUpdate validates options of the request and returns update of
+// the package document, options which are not specified are not changed.
+func (service *PackageService) getPackageUpdate(
+	request *proto.RequestUpdatePackage,
+) (bson.M, error) {
+	set := bson.M{}
+	unset := bson.M{}
+
+	setOrUnset := func(key string, value interface{}, empty bool) {
+		if empty {
+			unset[key] = ""
+		} else {
+			set[key] = value
+		}
+	}
+
+	if request.Priority != nil {
+		set["priority"] = *request.Priority
+	}
+
+	if request.CloneURL != nil {
+		if *request.CloneURL != "" && !proto.IsValidCloneURL(*request.CloneURL) {
+			return nil, ErrorInvalidCloneURL
+		}
+
+		set["clone_url"] = *request.CloneURL
+	}
+
+	if request.Schedule != nil {
+		rebuild, err := schedule.Parse(*request.Schedule)
+		if err != nil {
+			return nil, ErrorInvalidSchedule
+		}
+
+		setOrUnset("schedule", rebuild, rebuild == nil)
+	}
+
+	if request.Timeout != nil {
+		timeout := *request.Timeout
+		if timeout != 0 && timeout < MinTimeout {
+			return nil, ErrorInvalidTimeout
+		}
+
+		setOrUnset("timeout", timeout, timeout == 0)
+	}
+
+	if request.CPU != nil {
+		if *request.CPU < 0 {
+			return nil, ErrorInvalidResources
+		}
+
+		setOrUnset("resources.cpu", *request.CPU, *request.CPU == 0)
+	}
+
+	if request.Memory != nil {
+		memory := *request.Memory
+		if memory < 0 || (memory > 0 && memory < MinMemory) {
+			return nil, ErrorInvalidResources
+		}
+
+		setOrUnset("resources.memory", memory, memory == 0)
+	}
+
+	if request.Class != nil {
+		class := *request.Class
+		if _, ok := service.processor.Classes[class]; class != "" && !ok {
+			return nil, ErrorNoSuchClass
+		}
+
+		setOrUnset("class", class, class == "")
+	}
+
+	for name, value := range request.Env {
+		if !proto.IsValidEnvName(name) {
+			return nil, ErrorInvalidEnv
+		}
+
+		setOrUnset("env."+name, value, value == "")
+	}
+
+	if request.Repos != nil {
+		repos, err := service.repos.Resolve(*request.Repos)
+		if err != nil {
+			return nil, err
+		}
+
+		set["repos"] = repos
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	return update, nil
+}
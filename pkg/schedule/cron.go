@@ -0,0 +1,238 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchLimit is how far Next looks for a matching time, expressions
+// like "0 0 30 2 *" never match.
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonths = []string{
+	"jan", "feb", "mar", "apr", "may", "jun",
+	"jul", "aug", "sep", "oct", "nov", "dec",
+}
+
+var cronWeekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+type cronField struct {
+	min   int
+	max   int
+	names []string
+}
+
+var (
+	cronMinute  = cronField{min: 0, max: 59}
+	cronHour    = cronField{min: 0, max: 23}
+	cronDay     = cronField{min: 1, max: 31}
+	cronMonth   = cronField{min: 1, max: 12, names: cronMonths}
+	cronWeekday = cronField{min: 0, max: 7, names: cronWeekdays}
+)
+
+// Cron is a parsed cron expression with five fields: minute, hour, day of
+// month, month and day of week.
+type Cron struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+
+	// as in cron, if both days and weekdays are restricted, time matches
+	// if any of them matches
+	anyDay     bool
+	anyWeekday bool
+}
+
+// ParseCron parses cron expression, lists (1,2), ranges (1-5), steps (*/2),
+// names of months and weekdays and macros like @daily are supported.
+func ParseCron(expr string) (*Cron, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf(
+			"cron expression should have 5 fields, got %d: %q",
+			len(fields), expr,
+		)
+	}
+
+	var (
+		cron Cron
+		err  error
+	)
+
+	for _, target := range []struct {
+		bits  *uint64
+		field cronField
+		value string
+	}{
+		{&cron.minutes, cronMinute, fields[0]},
+		{&cron.hours, cronHour, fields[1]},
+		{&cron.days, cronDay, fields[2]},
+		{&cron.months, cronMonth, fields[3]},
+		{&cron.weekdays, cronWeekday, fields[4]},
+	} {
+		*target.bits, err = parseCronField(target.value, target.field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron field %q: %s", target.value, err)
+		}
+	}
+
+	// 7 is sunday as well as 0
+	if cron.weekdays&(1<<7) != 0 {
+		cron.weekdays |= 1
+	}
+
+	cron.anyDay = fields[2] == "*" || fields[2] == "?"
+	cron.anyWeekday = fields[4] == "*" || fields[4] == "?"
+
+	return &cron, nil
+}
+
+func parseCronField(value string, field cronField) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(value, ",") {
+		step := 1
+
+		if slash := strings.Index(part, "/"); slash >= 0 {
+			var err error
+
+			step, err = strconv.Atoi(part[slash+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step: %q", part[slash+1:])
+			}
+
+			part = part[:slash]
+		}
+
+		min, max := field.min, field.max
+
+		switch {
+		case part == "*" || part == "?":
+
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+
+			min, err = parseCronValue(bounds[0], field)
+			if err != nil {
+				return 0, err
+			}
+
+			max, err = parseCronValue(bounds[1], field)
+			if err != nil {
+				return 0, err
+			}
+
+			if min > max {
+				return 0, fmt.Errorf("invalid range: %q", part)
+			}
+
+		default:
+			value, err := parseCronValue(part, field)
+			if err != nil {
+				return 0, err
+			}
+
+			min = value
+			if step == 1 {
+				max = value
+			}
+		}
+
+		for value := min; value <= max; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseCronValue(value string, field cronField) (int, error) {
+	for index, name := range field.names {
+		if strings.ToLower(value) == name {
+			return index + field.min, nil
+		}
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value: %q", value)
+	}
+
+	if number < field.min || number > field.max {
+		return 0, fmt.Errorf(
+			"value %d is out of range %d-%d", number, field.min, field.max,
+		)
+	}
+
+	return number, nil
+}
+
+// Next returns the first time matching the expression after specified
+// time, zero time is returned if nothing matches in the next five years.
+func (cron *Cron) Next(after time.Time) time.Time {
+	next := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.Add(cronSearchLimit)
+
+	for next.Before(limit) {
+		if cron.months&(1<<uint(next.Month())) == 0 {
+			next = time.Date(
+				next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location(),
+			)
+			continue
+		}
+
+		if !cron.matchesDay(next) {
+			next = time.Date(
+				next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0,
+				next.Location(),
+			)
+			continue
+		}
+
+		if cron.hours&(1<<uint(next.Hour())) == 0 {
+			next = next.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+
+		if cron.minutes&(1<<uint(next.Minute())) == 0 {
+			next = next.Add(time.Minute)
+			continue
+		}
+
+		return next
+	}
+
+	return time.Time{}
+}
+
+func (cron *Cron) matchesDay(value time.Time) bool {
+	day := cron.days&(1<<uint(value.Day())) != 0
+	weekday := cron.weekdays&(1<<uint(value.Weekday())) != 0
+
+	if cron.anyDay || cron.anyWeekday {
+		return day && weekday
+	}
+
+	return day || weekday
+}
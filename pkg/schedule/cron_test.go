@@ -0,0 +1,78 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCron_Next(t *testing.T) {
+	test := assert.New(t)
+
+	// 2021-03-15 is monday
+	after := time.Date(2021, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	testcases := []struct {
+		Expr string
+		Next time.Time
+	}{
+		{"* * * * *", time.Date(2021, 3, 15, 10, 31, 0, 0, time.UTC)},
+		{"30 * * * *", time.Date(2021, 3, 15, 11, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 3, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 4 * * *", time.Date(2021, 3, 16, 4, 0, 0, 0, time.UTC)},
+		{"0 4,12 * * *", time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2021, 3, 15, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * sun", time.Date(2021, 3, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, 3, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * mon-fri", time.Date(2021, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC)},
+
+		// restricted day of month and day of week match any of them
+		{"0 0 20 * mon", time.Date(2021, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * fri", time.Date(2021, 3, 19, 0, 0, 0, 0, time.UTC)},
+
+		{"@hourly", time.Date(2021, 3, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2021, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2021, 3, 21, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, testcase := range testcases {
+		cron, err := ParseCron(testcase.Expr)
+		if !test.NoError(err, testcase.Expr) {
+			continue
+		}
+
+		test.Equal(testcase.Next, cron.Next(after), testcase.Expr)
+	}
+}
+
+func TestParseCron_ReturnsErrorOnInvalidExpression(t *testing.T) {
+	test := assert.New(t)
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@sometimes",
+	} {
+		_, err := ParseCron(expr)
+		test.Error(err, expr)
+	}
+}
@@ -0,0 +1,341 @@
+package schedule
+
+import (
+	"sort"
+	"time"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+)
+
+// Processor describes settings of the instance which processes the queue,
+// Plan assumes other instances have the same settings.
+type Processor struct {
+	Instance string
+	Threads  int
+	Defaults Defaults
+
+	// Aging is how long a package has to wait for a build to get its
+	// priority raised by one, zero disables aging.
+	Aging time.Duration
+
+	// Classes holds limits of concurrent builds of resource classes, zero
+	// means no limit except the number of threads.
+	Classes map[string]int
+}
+
+// IsDue reports whether the package should be built now according to its
+// schedule. Packages rebuilt on upstream change are never due, the
+// processor checks their upstream instead.
+func IsDue(pkg proto.Package, defaults Defaults, now time.Time) bool {
+	if pkg.Frozen || pkg.Paused != nil {
+		return false
+	}
+
+	next := Next(pkg, defaults)
+	if next.IsZero() {
+		return false
+	}
+
+	return !now.Before(next)
+}
+
+// Waiting returns how long the package has been due for a build.
+func Waiting(pkg proto.Package, defaults Defaults, now time.Time) time.Duration {
+	next := Next(pkg, defaults)
+	if next.IsZero() || now.Before(next) {
+		return 0
+	}
+
+	return now.Sub(next)
+}
+
+// Effective returns priority of the package raised by one for every aging
+// period it has been waiting for a build.
+func Effective(pkg proto.Package, waiting time.Duration, aging time.Duration) int {
+	if aging <= 0 {
+		return pkg.Priority
+	}
+
+	return pkg.Priority + int(waiting/aging)
+}
+
+// Order sorts packages in order they are dispatched: by effective priority,
+// then by waiting time, so packages of low priority are not starved.
+func Order(pkgs []proto.Package, processor Processor, now time.Time) {
+	type key struct {
+		effective int
+		waiting   time.Duration
+	}
+
+	keys := map[string]key{}
+	for _, pkg := range pkgs {
+		waiting := Waiting(pkg, processor.Defaults, now)
+
+		keys[pkg.Name] = key{
+			effective: Effective(pkg, waiting, processor.Aging),
+			waiting:   waiting,
+		}
+	}
+
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		a, b := keys[pkgs[i].Name], keys[pkgs[j].Name]
+		if a.effective != b.effective {
+			return a.effective > b.effective
+		}
+
+		return a.waiting > b.waiting
+	})
+}
+
+// Plan returns running builds followed by packages which are due, in order
+// the processor dispatches them, with estimated start and finish based on
+// durations of previous builds.
+//
+// Waiting builds are spread across instances which are running builds
+// now, each is assumed to have threads and limits of resource classes of
+// the specified processor since settings of other instances are not known;
+// the specified instance is assumed if nothing is running. So instances
+// of waiting builds are estimated as well as their start and finish.
+func Plan(
+	pkgs []proto.Package,
+	durations map[string]time.Duration,
+	processor Processor,
+	now time.Time,
+) []proto.QueueItem {
+	pkgs = append([]proto.Package{}, pkgs...)
+
+	Order(pkgs, processor, now)
+
+	fallback := getAverage(durations)
+
+	getDuration := func(name string) time.Duration {
+		if duration, ok := durations[name]; ok {
+			return duration
+		}
+
+		return fallback
+	}
+
+	workers := getWorkers(pkgs, processor, now)
+
+	queue := []proto.QueueItem{}
+
+	for _, pkg := range pkgs {
+		if pkg.Status != proto.BuildStatusProcessing.String() {
+			continue
+		}
+
+		duration := getDuration(pkg.Name)
+
+		finish := pkg.Date.Add(duration)
+		if finish.Before(now) {
+			finish = now
+		}
+
+		for _, worker := range workers {
+			if worker.instance == pkg.Instance {
+				worker.occupy(pkg.Class, now, finish.Sub(now))
+			}
+		}
+
+		queue = append(queue, proto.QueueItem{
+			Package:   pkg.Name,
+			Status:    pkg.Status,
+			Priority:  pkg.Priority,
+			Effective: pkg.Priority,
+			Class:     pkg.Class,
+			Instance:  pkg.Instance,
+			Duration:  duration,
+			Start:     pkg.Date,
+			Finish:    finish,
+		})
+	}
+
+	for _, pkg := range pkgs {
+		if pkg.Status == proto.BuildStatusProcessing.String() ||
+			!IsDue(pkg, processor.Defaults, now) {
+			continue
+		}
+
+		duration := getDuration(pkg.Name)
+		waiting := Waiting(pkg, processor.Defaults, now)
+
+		// the build is taken by the instance which is free first
+		chosen := workers[0]
+		earliest, _, _ := chosen.find(pkg.Class, now)
+		for _, worker := range workers[1:] {
+			start, _, _ := worker.find(pkg.Class, now)
+			if start.Before(earliest) {
+				chosen, earliest = worker, start
+			}
+		}
+
+		start, finish := chosen.occupy(pkg.Class, now, duration)
+
+		queue = append(queue, proto.QueueItem{
+			Package:   pkg.Name,
+			Status:    pkg.Status,
+			Priority:  pkg.Priority,
+			Effective: Effective(pkg, waiting, processor.Aging),
+			Class:     pkg.Class,
+			Instance:  chosen.instance,
+			Waiting:   waiting,
+			Duration:  duration,
+			Start:     start,
+			Finish:    finish,
+		})
+	}
+
+	return queue
+}
+
+// worker holds threads and slots of resource classes of an instance.
+type worker struct {
+	instance string
+	threads  slots
+	classes  map[string]slots
+}
+
+// getWorkers returns workers of instances which are running builds sorted
+// by name, or the worker of the processor if nothing is running.
+func getWorkers(
+	pkgs []proto.Package,
+	processor Processor,
+	now time.Time,
+) []*worker {
+	instances := []string{}
+	seen := map[string]bool{}
+	for _, pkg := range pkgs {
+		if pkg.Status != proto.BuildStatusProcessing.String() ||
+			seen[pkg.Instance] {
+			continue
+		}
+
+		seen[pkg.Instance] = true
+		instances = append(instances, pkg.Instance)
+	}
+
+	if len(instances) == 0 {
+		instances = append(instances, processor.Instance)
+	}
+
+	sort.Strings(instances)
+
+	workers := []*worker{}
+	for _, instance := range instances {
+		classes := map[string]slots{}
+		for class, limit := range processor.Classes {
+			if limit > 0 {
+				classes[class] = newSlots(limit, now)
+			}
+		}
+
+		workers = append(workers, &worker{
+			instance: instance,
+			threads:  newSlots(processor.Threads, now),
+			classes:  classes,
+		})
+	}
+
+	return workers
+}
+
+// find returns when a build of the class can be started with the slot of
+// the class and the thread it takes, builds of the class over the limit are
+// not dispatched and don't take threads while waiting, so the thread is
+// chosen by the time the slot is free.
+func (worker *worker) find(
+	class string,
+	now time.Time,
+) (start time.Time, slot int, thread int) {
+	start = now
+	if limited, ok := worker.classes[class]; ok {
+		slot = limited.earliest()
+		start = limited[slot]
+	}
+
+	thread = worker.threads.latestBy(start)
+	if worker.threads[thread].After(start) {
+		start = worker.threads[thread]
+	}
+
+	return start, slot, thread
+}
+
+// occupy takes the slot of the class and the thread for the build.
+func (worker *worker) occupy(
+	class string,
+	now time.Time,
+	duration time.Duration,
+) (time.Time, time.Time) {
+	start, slot, thread := worker.find(class, now)
+	finish := start.Add(duration)
+
+	worker.threads[thread] = finish
+	if limited, ok := worker.classes[class]; ok {
+		limited[slot] = finish
+	}
+
+	return start, finish
+}
+
+// slots holds time when each of threads finishes its current build.
+type slots []time.Time
+
+func newSlots(size int, now time.Time) slots {
+	if size <= 0 {
+		size = 1
+	}
+
+	free := make(slots, size)
+	for i := range free {
+		free[i] = now
+	}
+
+	return free
+}
+
+func (free slots) earliest() int {
+	slot := 0
+	for i := range free {
+		if free[i].Before(free[slot]) {
+			slot = i
+		}
+	}
+
+	return slot
+}
+
+// latestBy returns the slot which is free by specified time and keeps
+// earlier slots for other builds, or the earliest slot if all are busy.
+func (free slots) latestBy(deadline time.Time) int {
+	slot := -1
+	for i := range free {
+		if free[i].After(deadline) {
+			continue
+		}
+
+		if slot == -1 || free[i].After(free[slot]) {
+			slot = i
+		}
+	}
+
+	if slot == -1 {
+		return free.earliest()
+	}
+
+	return slot
+}
+
+func getAverage(durations map[string]time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+
+	var total time.Duration
+	for _, duration := range durations {
+		total += duration
+	}
+
+	return total / time.Duration(len(durations))
+}
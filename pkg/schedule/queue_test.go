@@ -0,0 +1,258 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlan(t *testing.T) {
+	test := assert.New(t)
+
+	now := time.Date(2021, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	processor := Processor{
+		Instance: "local",
+		Threads:  2,
+		Defaults: Defaults{
+			Processing: time.Hour,
+			Success:    time.Hour,
+			Failure:    time.Hour,
+		},
+	}
+
+	pkgs := []proto.Package{
+		{
+			Name:   "fresh",
+			Status: proto.BuildStatusSuccess.String(),
+			Date:   now.Add(-time.Minute),
+		},
+		{
+			Name:   "low",
+			Status: proto.BuildStatusQueued.String(),
+			Date:   now.Add(-time.Minute),
+		},
+		{
+			Name:     "high",
+			Status:   proto.BuildStatusFailure.String(),
+			Date:     now.Add(-2 * time.Hour),
+			Priority: 10,
+		},
+		{
+			Name:     "running",
+			Status:   proto.BuildStatusProcessing.String(),
+			Instance: "local",
+			Date:     now.Add(-5 * time.Minute),
+		},
+		{
+			Name:     "remote",
+			Status:   proto.BuildStatusProcessing.String(),
+			Instance: "remote",
+			Date:     now.Add(-5 * time.Minute),
+		},
+		{
+			Name:   "frozen",
+			Status: proto.BuildStatusQueued.String(),
+			Date:   now.Add(-time.Minute),
+			Frozen: true,
+		},
+		{
+			Name:     "upstream",
+			Status:   proto.BuildStatusSuccess.String(),
+			Date:     now.Add(-2 * time.Hour),
+			Schedule: &proto.Schedule{Upstream: true},
+		},
+		{
+			Name:   "unknown",
+			Status: proto.BuildStatusQueued.String(),
+			Date:   now.Add(-time.Minute),
+		},
+	}
+
+	durations := map[string]time.Duration{
+		"running": 10 * time.Minute,
+		"remote":  10 * time.Minute,
+		"high":    20 * time.Minute,
+		"low":     30 * time.Minute,
+	}
+
+	// average of known durations is used for unknown package
+	average := 17*time.Minute + 30*time.Second
+
+	test.Equal(
+		[]proto.QueueItem{
+			{
+				Package:  "running",
+				Status:   proto.BuildStatusProcessing.String(),
+				Instance: "local",
+				Duration: 10 * time.Minute,
+				Start:    now.Add(-5 * time.Minute),
+				Finish:   now.Add(5 * time.Minute),
+			},
+			{
+				Package:  "remote",
+				Status:   proto.BuildStatusProcessing.String(),
+				Instance: "remote",
+				Duration: 10 * time.Minute,
+				Start:    now.Add(-5 * time.Minute),
+				Finish:   now.Add(5 * time.Minute),
+			},
+			{
+				Package:   "high",
+				Status:    proto.BuildStatusFailure.String(),
+				Priority:  10,
+				Effective: 10,
+				Instance:  "local",
+				Waiting:   time.Hour,
+				Duration:  20 * time.Minute,
+				Start:     now,
+				Finish:    now.Add(20 * time.Minute),
+			},
+			// both instances running builds are assumed to have 2 threads
+			{
+				Package:  "low",
+				Status:   proto.BuildStatusQueued.String(),
+				Instance: "remote",
+				Waiting:  time.Minute,
+				Duration: 30 * time.Minute,
+				Start:    now,
+				Finish:   now.Add(30 * time.Minute),
+			},
+			{
+				Package:  "unknown",
+				Status:   proto.BuildStatusQueued.String(),
+				Instance: "local",
+				Waiting:  time.Minute,
+				Duration: average,
+				Start:    now.Add(5 * time.Minute),
+				Finish:   now.Add(5*time.Minute + average),
+			},
+		},
+		Plan(pkgs, durations, processor, now),
+	)
+}
+
+func TestPlan_LimitsClasses(t *testing.T) {
+	test := assert.New(t)
+
+	now := time.Date(2021, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	processor := Processor{
+		Instance: "local",
+		Threads:  3,
+		Classes:  map[string]int{"heavy": 1, "light": 0},
+	}
+
+	pkgs := []proto.Package{
+		{Name: "llvm", Class: "heavy", Priority: 3},
+		{Name: "gcc", Class: "heavy", Priority: 2},
+		{Name: "yay", Class: "light", Priority: 1},
+		{Name: "fzf"},
+	}
+
+	for i := range pkgs {
+		pkgs[i].Status = proto.BuildStatusQueued.String()
+		pkgs[i].Date = now
+	}
+
+	durations := map[string]time.Duration{
+		"llvm": 3 * time.Hour,
+		"gcc":  2 * time.Hour,
+		"yay":  time.Minute,
+		"fzf":  time.Minute,
+	}
+
+	type estimate struct {
+		Package string
+		Start   time.Time
+		Finish  time.Time
+	}
+
+	estimates := []estimate{}
+	for _, item := range Plan(pkgs, durations, processor, now) {
+		// nothing is running, so the processor itself is assumed
+		test.Equal("local", item.Instance)
+
+		estimates = append(estimates, estimate{item.Package, item.Start, item.Finish})
+	}
+
+	// heavy builds are serialised while light ones use other threads
+	test.Equal(
+		[]estimate{
+			{"llvm", now, now.Add(3 * time.Hour)},
+			{"gcc", now.Add(3 * time.Hour), now.Add(5 * time.Hour)},
+			{"yay", now, now.Add(time.Minute)},
+			{"fzf", now, now.Add(time.Minute)},
+		},
+		estimates,
+	)
+}
+
+func TestOrder_RaisesPriorityByAging(t *testing.T) {
+	test := assert.New(t)
+
+	now := time.Date(2021, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	processor := Processor{
+		Aging: time.Hour,
+		Defaults: Defaults{
+			Success: time.Hour,
+		},
+	}
+
+	pkgs := []proto.Package{
+		{Name: "important", Priority: 2, Date: now.Add(-time.Hour)},
+		{Name: "starving", Priority: 0, Date: now.Add(-4 * time.Hour)},
+		{Name: "waiting", Priority: 2, Date: now.Add(-2 * time.Hour)},
+		{Name: "fresh", Priority: 1, Date: now.Add(-time.Hour)},
+	}
+
+	for i := range pkgs {
+		pkgs[i].Status = proto.BuildStatusSuccess.String()
+	}
+
+	Order(pkgs, processor, now)
+
+	names := []string{}
+	for _, pkg := range pkgs {
+		names = append(names, pkg.Name)
+	}
+
+	// starving waits 3h and gets priority 3, waiting gets 3 as well but
+	// has been waiting for less time
+	test.Equal([]string{"starving", "waiting", "important", "fresh"}, names)
+
+	test.Equal(2, Effective(pkgs[0], 150*time.Minute, time.Hour))
+	test.Equal(0, Effective(pkgs[0], 150*time.Minute, 0))
+}
+
+func TestIsDue(t *testing.T) {
+	test := assert.New(t)
+
+	now := time.Date(2021, 3, 15, 10, 30, 0, 0, time.UTC)
+	defaults := Defaults{Success: time.Hour}
+
+	pkg := proto.Package{
+		Status: proto.BuildStatusSuccess.String(),
+		Date:   now.Add(-time.Hour),
+	}
+
+	test.True(IsDue(pkg, defaults, now))
+
+	pkg.Frozen = true
+	test.False(IsDue(pkg, defaults, now))
+
+	pkg.Frozen = false
+	pkg.Paused = &proto.Pause{By: "operator"}
+	test.False(IsDue(pkg, defaults, now))
+
+	pkg.Paused = nil
+	pkg.Date = now
+	test.False(IsDue(pkg, defaults, now))
+
+	pkg.Schedule = &proto.Schedule{Upstream: true}
+	pkg.Date = now.Add(-time.Hour)
+	test.False(IsDue(pkg, defaults, now))
+}
@@ -0,0 +1,214 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+)
+
+const (
+	specUpstream = "upstream"
+	specEvery    = "every "
+)
+
+// Defaults are global intervals used for packages without own schedule.
+type Defaults struct {
+	// Processing is how long a build can be stuck in processing before
+	// the package is built again, it's extended to the build timeout of the
+	// package if the timeout is longer.
+	Processing time.Duration
+	Success    time.Duration
+	Failure    time.Duration
+
+	// Timeout is the global build timeout used for packages without own
+	// timeout.
+	Timeout time.Duration
+
+	// Backoff caps the interval after consecutive failures, which is
+	// doubled after every failure starting from Failure. Failed packages
+	// are retried every Failure if it's zero.
+	Backoff time.Duration
+}
+
+// Parse parses schedule specification which is one of:
+//   - cron expression like "0 4 * * *" or "@daily",
+//   - minimum interval like "every 6h" or "every 2d",
+//   - "upstream" to rebuild only on upstream change.
+//
+// Empty specification means the package uses global intervals.
+func Parse(spec string) (*proto.Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	switch {
+	case spec == "":
+		return nil, nil
+
+	case spec == specUpstream:
+		return &proto.Schedule{Upstream: true}, nil
+
+	case strings.HasPrefix(spec, specEvery):
+		interval, err := parseInterval(
+			strings.TrimSpace(strings.TrimPrefix(spec, specEvery)),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		return &proto.Schedule{Interval: interval}, nil
+	}
+
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if cron.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron expression never matches: %q", spec)
+	}
+
+	return &proto.Schedule{Cron: spec}, nil
+}
+
+func parseInterval(value string) (time.Duration, error) {
+	var (
+		interval time.Duration
+		err      error
+	)
+
+	if strings.HasSuffix(value, "d") {
+		var days int
+
+		days, err = strconv.Atoi(strings.TrimSuffix(value, "d"))
+		interval = time.Duration(days) * 24 * time.Hour
+	} else {
+		interval, err = time.ParseDuration(value)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval: %q", value)
+	}
+
+	if interval < time.Minute {
+		return 0, fmt.Errorf("interval should be at least 1m: %q", value)
+	}
+
+	return interval, nil
+}
+
+// Format returns specification of the schedule, it can be parsed back by
+// Parse.
+func Format(schedule *proto.Schedule) string {
+	switch {
+	case schedule == nil:
+		return ""
+
+	case schedule.Upstream:
+		return specUpstream
+
+	case schedule.Interval > 0:
+		return specEvery + schedule.Interval.String()
+	}
+
+	return schedule.Cron
+}
+
+// Next returns time of the next planned build of the package. Zero time
+// is returned for packages which are rebuilt only on upstream change,
+// including quarantined ones. Packages which fail repeatedly are not
+// retried before the backoff passes even if their schedule says so.
+func Next(pkg proto.Package, defaults Defaults) time.Time {
+	switch pkg.Status {
+	case proto.BuildStatusProcessing.String():
+		return pkg.Date.Add(processing(pkg, defaults))
+
+	case proto.BuildStatusSuccess.String(),
+		proto.BuildStatusFailure.String():
+
+	default:
+		return pkg.Date
+	}
+
+	if pkg.Quarantined {
+		return time.Time{}
+	}
+
+	next := scheduled(pkg, defaults)
+	if next.IsZero() || pkg.Status != proto.BuildStatusFailure.String() {
+		return next
+	}
+
+	retry := pkg.Date.Add(Backoff(pkg.Failures, defaults))
+	if retry.After(next) {
+		return retry
+	}
+
+	return next
+}
+
+// processing returns how long the package stays in processing before it's
+// built again, a build which is still running should not be started twice.
+func processing(pkg proto.Package, defaults Defaults) time.Duration {
+	timeout := pkg.Timeout
+	if timeout <= 0 {
+		timeout = defaults.Timeout
+	}
+
+	if timeout > defaults.Processing {
+		return timeout
+	}
+
+	return defaults.Processing
+}
+
+// Backoff returns minimum interval between builds of the package after
+// specified number of consecutive failures.
+func Backoff(failures int, defaults Defaults) time.Duration {
+	backoff := defaults.Failure
+	if defaults.Backoff <= 0 {
+		return backoff
+	}
+
+	for i := 1; i < failures && backoff < defaults.Backoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > defaults.Backoff {
+		return defaults.Backoff
+	}
+
+	return backoff
+}
+
+func scheduled(pkg proto.Package, defaults Defaults) time.Time {
+	schedule := pkg.Schedule
+
+	switch {
+	case schedule == nil:
+		if pkg.Status == proto.BuildStatusSuccess.String() {
+			return pkg.Date.Add(defaults.Success)
+		}
+
+		return pkg.Date.Add(defaults.Failure)
+
+	case schedule.Upstream:
+		return time.Time{}
+
+	case schedule.Interval > 0:
+		return pkg.Date.Add(schedule.Interval)
+	}
+
+	cron, err := ParseCron(schedule.Cron)
+	if err != nil {
+		// schedules are validated when added, fall back to global intervals
+		// if it's broken anyway
+		return scheduled(
+			proto.Package{Status: pkg.Status, Date: pkg.Date},
+			defaults,
+		)
+	}
+
+	return cron.Next(pkg.Date)
+}
@@ -0,0 +1,204 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	test := assert.New(t)
+
+	testcases := []struct {
+		Spec     string
+		Schedule *proto.Schedule
+		Format   string
+	}{
+		{"", nil, ""},
+		{"upstream", &proto.Schedule{Upstream: true}, "upstream"},
+		{"every 6h", &proto.Schedule{Interval: 6 * time.Hour}, "every 6h0m0s"},
+		{"every 2d", &proto.Schedule{Interval: 48 * time.Hour}, "every 48h0m0s"},
+		{"0 4 * * *", &proto.Schedule{Cron: "0 4 * * *"}, "0 4 * * *"},
+		{"@daily", &proto.Schedule{Cron: "@daily"}, "@daily"},
+	}
+
+	for _, testcase := range testcases {
+		schedule, err := Parse(testcase.Spec)
+		if !test.NoError(err, testcase.Spec) {
+			continue
+		}
+
+		test.Equal(testcase.Schedule, schedule, testcase.Spec)
+		test.Equal(testcase.Format, Format(schedule), testcase.Spec)
+
+		reparsed, err := Parse(Format(schedule))
+		test.NoError(err, testcase.Spec)
+		test.Equal(schedule, reparsed, testcase.Spec)
+	}
+
+	for _, spec := range []string{
+		"every",
+		"every 10s",
+		"every xd",
+		"every day",
+		"0 0 30 2 *",
+		"tomorrow",
+	} {
+		_, err := Parse(spec)
+		test.Error(err, spec)
+	}
+}
+
+func TestNext(t *testing.T) {
+	test := assert.New(t)
+
+	date := time.Date(2021, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	defaults := Defaults{
+		Processing: 30 * time.Minute,
+		Success:    time.Hour,
+		Failure:    2 * time.Hour,
+	}
+
+	testcases := []struct {
+		Status   proto.BuildStatus
+		Schedule *proto.Schedule
+		Next     time.Time
+	}{
+		{proto.BuildStatusQueued, nil, date},
+		{proto.BuildStatusCancelled, nil, date},
+		{proto.BuildStatusProcessing, nil, date.Add(30 * time.Minute)},
+		{proto.BuildStatusSuccess, nil, date.Add(time.Hour)},
+		{proto.BuildStatusFailure, nil, date.Add(2 * time.Hour)},
+		{
+			proto.BuildStatusSuccess,
+			&proto.Schedule{Interval: 6 * time.Hour},
+			date.Add(6 * time.Hour),
+		},
+		{
+			proto.BuildStatusFailure,
+			&proto.Schedule{Cron: "0 4 * * *"},
+			time.Date(2021, 3, 16, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			proto.BuildStatusProcessing,
+			&proto.Schedule{Cron: "0 4 * * *"},
+			date.Add(30 * time.Minute),
+		},
+		{proto.BuildStatusSuccess, &proto.Schedule{Upstream: true}, time.Time{}},
+		{proto.BuildStatusQueued, &proto.Schedule{Upstream: true}, date},
+	}
+
+	for _, testcase := range testcases {
+		pkg := proto.Package{
+			Status:   testcase.Status.String(),
+			Date:     date,
+			Schedule: testcase.Schedule,
+		}
+
+		test.Equal(
+			testcase.Next, Next(pkg, defaults),
+			"%s %s", testcase.Status, Format(testcase.Schedule),
+		)
+	}
+}
+
+func TestNext_BacksOffFailures(t *testing.T) {
+	test := assert.New(t)
+
+	date := time.Date(2021, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	defaults := Defaults{
+		Success: time.Hour,
+		Failure: time.Hour,
+		Backoff: 6 * time.Hour,
+	}
+
+	testcases := []struct {
+		Failures int
+		Schedule *proto.Schedule
+		Next     time.Time
+	}{
+		{0, nil, date.Add(time.Hour)},
+		{1, nil, date.Add(time.Hour)},
+		{2, nil, date.Add(2 * time.Hour)},
+		{3, nil, date.Add(4 * time.Hour)},
+		{4, nil, date.Add(6 * time.Hour)},
+		{20, nil, date.Add(6 * time.Hour)},
+		{1, &proto.Schedule{Interval: 3 * time.Hour}, date.Add(3 * time.Hour)},
+		{4, &proto.Schedule{Interval: 3 * time.Hour}, date.Add(6 * time.Hour)},
+		{
+			4,
+			&proto.Schedule{Cron: "0 4 * * *"},
+			time.Date(2021, 3, 16, 4, 0, 0, 0, time.UTC),
+		},
+		{4, &proto.Schedule{Upstream: true}, time.Time{}},
+	}
+
+	for _, testcase := range testcases {
+		pkg := proto.Package{
+			Status:   proto.BuildStatusFailure.String(),
+			Date:     date,
+			Schedule: testcase.Schedule,
+			Failures: testcase.Failures,
+		}
+
+		test.Equal(
+			testcase.Next, Next(pkg, defaults),
+			"%d failures %s", testcase.Failures, Format(testcase.Schedule),
+		)
+	}
+
+	defaults.Backoff = 0
+
+	test.Equal(
+		date.Add(time.Hour),
+		Next(proto.Package{
+			Status:   proto.BuildStatusFailure.String(),
+			Date:     date,
+			Failures: 5,
+		}, defaults),
+	)
+}
+
+func TestNext_SkipsQuarantined(t *testing.T) {
+	test := assert.New(t)
+
+	date := time.Date(2021, 3, 15, 10, 30, 0, 0, time.UTC)
+	defaults := Defaults{Failure: time.Hour, Backoff: 6 * time.Hour}
+
+	pkg := proto.Package{
+		Status:      proto.BuildStatusFailure.String(),
+		Date:        date,
+		Failures:    10,
+		Quarantined: true,
+	}
+
+	test.True(Next(pkg, defaults).IsZero())
+
+	// aurora rebuild queues the package
+	pkg.Status = proto.BuildStatusQueued.String()
+	test.Equal(date, Next(pkg, defaults))
+}
+
+func TestNext_WaitsForBuildTimeout(t *testing.T) {
+	test := assert.New(t)
+
+	date := time.Date(2021, 3, 15, 10, 30, 0, 0, time.UTC)
+	defaults := Defaults{Processing: 30 * time.Minute, Timeout: 10 * time.Minute}
+
+	pkg := proto.Package{
+		Status: proto.BuildStatusProcessing.String(),
+		Date:   date,
+	}
+
+	test.Equal(date.Add(30*time.Minute), Next(pkg, defaults))
+
+	defaults.Timeout = time.Hour
+	test.Equal(date.Add(time.Hour), Next(pkg, defaults))
+
+	pkg.Timeout = 2 * time.Hour
+	test.Equal(date.Add(2*time.Hour), Next(pkg, defaults))
+}
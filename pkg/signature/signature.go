@@ -6,6 +6,8 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
 	"encoding/pem"
 	"io/ioutil"
 	"strconv"
@@ -16,6 +18,10 @@ import (
 
 const (
 	SignatureTTL = 30
+
+	// Header is the name of HTTP header carrying encoded signature for
+	// requests that can't have signature in the body.
+	Header = "X-Aurora-Signature"
 )
 
 type Signature struct {
@@ -85,3 +91,36 @@ func ReadPrivateKeyFile(path string) (*rsa.PrivateKey, error) {
 
 	return key, nil
 }
+
+// Encode returns signature in the form suitable for passing in HTTP header.
+func (sign Signature) Encode() string {
+	data, err := json.Marshal(sign)
+	if err != nil {
+		panic(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// Decode parses signature encoded by Encode.
+func Decode(encoded string) (*Signature, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to decode base64 signature",
+		)
+	}
+
+	var sign Signature
+
+	err = json.Unmarshal(data, &sign)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to unmarshal signature",
+		)
+	}
+
+	return &sign, nil
+}
@@ -0,0 +1,209 @@
+package staging
+
+import (
+	"errors"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/reconquest/karma-go"
+)
+
+// ErrorNotStaged is returned when the package has not been published to
+// any testing repository yet.
+var ErrorNotStaged = errors.New("package is not staged in any testing repository")
+
+// Staging keeps track of package versions published to testing
+// repositories until they are promoted to stable ones.
+type Staging struct {
+	collection *mgo.Collection
+}
+
+func NewStaging(collection *mgo.Collection) (*Staging, error) {
+	err := collection.EnsureIndex(mgo.Index{
+		Key:    []string{"package", "repo"},
+		Unique: true,
+	})
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to ensure index for staging collection",
+		)
+	}
+
+	return &Staging{collection: collection}, nil
+}
+
+// Stage records that the version has been published to the testing
+// repository, soak period starts over and reported problems are forgotten
+// only if the version differs from the staged one.
+func (staging *Staging) Stage(stage proto.Stage) error {
+	err := staging.collection.Update(
+		bson.M{
+			"package": stage.Package,
+			"repo":    stage.Repo,
+			"version": stage.Version,
+		},
+		bson.M{"$set": bson.M{"target": stage.Target}},
+	)
+	if err == nil {
+		return nil
+	}
+
+	if err != mgo.ErrNotFound {
+		return karma.Format(
+			err,
+			"unable to update stage of %s in %s", stage.Package, stage.Repo,
+		)
+	}
+
+	_, err = staging.collection.Upsert(
+		bson.M{
+			"package": stage.Package,
+			"repo":    stage.Repo,
+		},
+		bson.M{"$set": bson.M{
+			"target":    stage.Target,
+			"version":   stage.Version,
+			"published": stage.Published,
+			"problems":  []string{},
+			"failed":    "",
+			"error":     "",
+		}},
+	)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to stage %s in %s", stage.Package, stage.Repo,
+		)
+	}
+
+	return nil
+}
+
+// Find returns stages of the package in all testing repositories.
+func (staging *Staging) Find(pkg string) ([]proto.Stage, error) {
+	stages := []proto.Stage{}
+
+	err := staging.collection.Find(bson.M{"package": pkg}).
+		Sort("repo").
+		All(&stages)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to find stages of %s", pkg,
+		)
+	}
+
+	return stages, nil
+}
+
+// FindPending returns stages which are requested to be promoted or which
+// staged version hasn't been promoted yet and hasn't failed to.
+func (staging *Staging) FindPending() ([]proto.Stage, error) {
+	stages := []proto.Stage{}
+
+	err := staging.collection.Find(bson.M{
+		"$or": []bson.M{
+			{"requested": true},
+			{"$expr": bson.M{"$and": []bson.M{
+				{"$ne": []string{"$version", "$promoted"}},
+				{"$ne": []string{"$version", "$failed"}},
+			}}},
+		},
+	}).All(&stages)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			"unable to find pending stages",
+		)
+	}
+
+	return stages, nil
+}
+
+// Request asks to promote specified version of the package, empty version
+// means the staged one.
+func (staging *Staging) Request(pkg string, version string) error {
+	return staging.updatePackage(pkg, bson.M{"$set": bson.M{
+		"requested":         true,
+		"requested_version": version,
+	}})
+}
+
+// Report records a problem with the staged version of the package, such
+// version is not promoted automatically.
+func (staging *Staging) Report(pkg string, problem string) error {
+	return staging.updatePackage(pkg, bson.M{"$push": bson.M{
+		"problems": problem,
+	}})
+}
+
+// Promoted records that the version has been promoted to the target
+// repository.
+func (staging *Staging) Promoted(stage proto.Stage, version string) error {
+	return staging.update(stage, bson.M{"$set": bson.M{
+		"promoted":          version,
+		"promoted_at":       time.Now(),
+		"requested":         false,
+		"requested_version": "",
+		"failed":            "",
+		"error":             "",
+	}})
+}
+
+// Fail records that the promotion of the version has failed, it's not
+// retried until requested again or a new version is staged. Failure of a
+// requested version other than the staged one only cancels the request, so
+// the staged version is still promoted after the soak.
+func (staging *Staging) Fail(
+	stage proto.Stage,
+	version string,
+	reason error,
+) error {
+	if version != stage.Version {
+		return staging.update(stage, bson.M{"$set": bson.M{
+			"requested":         false,
+			"requested_version": "",
+		}})
+	}
+
+	return staging.update(stage, bson.M{"$set": bson.M{
+		"failed":            version,
+		"requested":         false,
+		"requested_version": "",
+		"error":             reason.Error(),
+	}})
+}
+
+func (staging *Staging) update(stage proto.Stage, update bson.M) error {
+	err := staging.collection.Update(
+		bson.M{"package": stage.Package, "repo": stage.Repo},
+		update,
+	)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to update stage of %s in %s", stage.Package, stage.Repo,
+		)
+	}
+
+	return nil
+}
+
+func (staging *Staging) updatePackage(pkg string, update bson.M) error {
+	info, err := staging.collection.UpdateAll(bson.M{"package": pkg}, update)
+	if err != nil {
+		return karma.Format(
+			err,
+			"unable to update stages of %s", pkg,
+		)
+	}
+
+	if info.Matched == 0 {
+		return ErrorNotStaged
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+// Package vercmp compares versions of packages the same way as pacman does.
+package vercmp
+
+import (
+	"strings"
+)
+
+// Compare compares versions in form [epoch:]pkgver[-pkgrel] and returns -1
+// if a is older than b, 0 if they are equal and 1 if a is newer than b.
+// Release is compared only if both versions have it.
+func Compare(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	epochA, versionA, releaseA := parse(a)
+	epochB, versionB, releaseB := parse(b)
+
+	result := compareSegments(epochA, epochB)
+	if result != 0 {
+		return result
+	}
+
+	result = compareSegments(versionA, versionB)
+	if result != 0 {
+		return result
+	}
+
+	if releaseA != "" && releaseB != "" {
+		return compareSegments(releaseA, releaseB)
+	}
+
+	return 0
+}
+
+// parse splits version into epoch, version and release, epoch is 0 if
+// not specified.
+func parse(full string) (epoch, version, release string) {
+	digits := 0
+	for digits < len(full) && isDigit(full[digits]) {
+		digits++
+	}
+
+	epoch = "0"
+	version = full
+
+	if digits < len(full) && full[digits] == ':' {
+		if digits > 0 {
+			epoch = full[:digits]
+		}
+
+		version = full[digits+1:]
+	}
+
+	separator := strings.LastIndexByte(version, '-')
+	if separator >= 0 {
+		release = version[separator+1:]
+		version = version[:separator]
+	}
+
+	return epoch, version, release
+}
+
+// compareSegments is a port of rpmvercmp: versions are split into
+// alphabetic and numeric segments which are compared one by one, numeric
+// segments are newer than alphabetic ones.
+func compareSegments(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	var one, two int
+	for one < len(a) && two < len(b) {
+		startA, startB := one, two
+
+		for one < len(a) && !isAlnum(a[one]) {
+			one++
+		}
+
+		for two < len(b) && !isAlnum(b[two]) {
+			two++
+		}
+
+		if one >= len(a) || two >= len(b) {
+			break
+		}
+
+		// different lengths of separators
+		if one-startA != two-startB {
+			if one-startA < two-startB {
+				return -1
+			}
+
+			return 1
+		}
+
+		endA, endB := one, two
+
+		numeric := isDigit(a[one])
+		if numeric {
+			for endA < len(a) && isDigit(a[endA]) {
+				endA++
+			}
+
+			for endB < len(b) && isDigit(b[endB]) {
+				endB++
+			}
+		} else {
+			for endA < len(a) && isAlpha(a[endA]) {
+				endA++
+			}
+
+			for endB < len(b) && isAlpha(b[endB]) {
+				endB++
+			}
+		}
+
+		segmentA, segmentB := a[one:endA], b[two:endB]
+
+		// segments of different types, numeric one is newer
+		if segmentB == "" {
+			if numeric {
+				return 1
+			}
+
+			return -1
+		}
+
+		if numeric {
+			segmentA = strings.TrimLeft(segmentA, "0")
+			segmentB = strings.TrimLeft(segmentB, "0")
+
+			if len(segmentA) > len(segmentB) {
+				return 1
+			}
+
+			if len(segmentA) < len(segmentB) {
+				return -1
+			}
+		}
+
+		switch {
+		case segmentA < segmentB:
+			return -1
+		case segmentA > segmentB:
+			return 1
+		}
+
+		one, two = endA, endB
+	}
+
+	restA, restB := a[one:], b[two:]
+
+	// all segments are equal, only separators differ
+	if restA == "" && restB == "" {
+		return 0
+	}
+
+	// remaining alphabetic segment is never newer than nothing, e.g.
+	// 1.0rc is older than 1.0, but 1.0.1 is newer than 1.0
+	if (restA == "" && !isAlpha(restB[0])) ||
+		(restA != "" && isAlpha(restA[0])) {
+		return -1
+	}
+
+	return 1
+}
+
+func isDigit(char byte) bool {
+	return char >= '0' && char <= '9'
+}
+
+func isAlpha(char byte) bool {
+	return (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+}
+
+func isAlnum(char byte) bool {
+	return isDigit(char) || isAlpha(char)
+}
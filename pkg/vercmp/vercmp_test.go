@@ -0,0 +1,127 @@
+package vercmp
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	test := assert.New(t)
+
+	// results of pacman's vercmp, every case is checked in both directions
+	testcases := []struct {
+		A      string
+		B      string
+		Result int
+	}{
+		// all similar length, no pkgrel
+		{"1.5.0", "1.5.0", 0},
+		{"1.5.1", "1.5.0", 1},
+
+		// mixed length
+		{"1.5.1", "1.5", 1},
+
+		// with pkgrel, simple
+		{"1.5.0-1", "1.5.0-1", 0},
+		{"1.5.0-1", "1.5.0-2", -1},
+		{"1.5.0-1", "1.5.1-1", -1},
+		{"1.5.0-2", "1.5.1-1", -1},
+
+		// with pkgrel, mixed lengths
+		{"1.5-1", "1.5.1-1", -1},
+		{"1.5-2", "1.5.1-1", -1},
+		{"1.5-2", "1.5.1-2", -1},
+
+		// mixed pkgrel inclusion
+		{"1.5", "1.5-1", 0},
+		{"1.5-1", "1.5", 0},
+		{"1.1-1", "1.1", 0},
+		{"1.0-1", "1.1", -1},
+		{"1.1-1", "1.0", 1},
+
+		// alphanumeric versions
+		{"1.5b-1", "1.5-1", -1},
+		{"1.5b", "1.5", -1},
+		{"1.5b-1", "1.5", -1},
+		{"1.5b", "1.5.1", -1},
+
+		// from the manpage
+		{"1.0a", "1.0alpha", -1},
+		{"1.0alpha", "1.0b", -1},
+		{"1.0b", "1.0beta", -1},
+		{"1.0beta", "1.0rc", -1},
+		{"1.0rc", "1.0", -1},
+
+		// alpha-dotted versions
+		{"1.5.a", "1.5", 1},
+		{"1.5.b", "1.5.a", 1},
+		{"1.5.1", "1.5.b", 1},
+
+		// alpha dots and dashes
+		{"1.5.b-1", "1.5.b", 0},
+		{"1.5-1", "1.5.b", -1},
+
+		// same/similar content, differing separators
+		{"2.0", "2_0", 0},
+		{"2.0_a", "2_0.a", 0},
+		{"2.0a", "2.0.a", -1},
+		{"2___a", "2_a", 1},
+
+		// epoch included version comparisons
+		{"0:1.0", "0:1.0", 0},
+		{"0:1.0", "0:1.1", -1},
+		{"1:1.0", "0:1.0", 1},
+		{"1:1.0", "0:1.1", 1},
+		{"1:1.0", "2:1.1", -1},
+
+		// epoch + sometimes present pkgrel
+		{"1:1.0", "0:1.0-1", 1},
+		{"1:1.0-1", "0:1.1-1", 1},
+
+		// epoch included on one version
+		{"0:1.0", "1.0", 0},
+		{"0:1.0", "1.1", -1},
+		{"0:1.1", "1.0", 1},
+		{"1:1.0", "1.0", 1},
+		{"1:1.0", "1.1", 1},
+		{"1:1.1", "1.1", 1},
+
+		// numeric segments are compared as numbers, not as strings
+		{"1.10-1", "1.9-1", 1},
+		{"1.010", "1.9", 1},
+		{"1.001", "1.1", 0},
+		{"20191104.r123.gabcdef-1", "20191104.r99.g123456-1", 1},
+		{"0.5.0.r1234.gabcdef-1", "0.5.0.r1233.gfedcba-2", 1},
+	}
+
+	for _, testcase := range testcases {
+		test.Equal(
+			testcase.Result,
+			Compare(testcase.A, testcase.B),
+			"%s vs %s", testcase.A, testcase.B,
+		)
+
+		test.Equal(
+			-testcase.Result,
+			Compare(testcase.B, testcase.A),
+			"%s vs %s", testcase.B, testcase.A,
+		)
+	}
+}
+
+func TestCompare_SortsVersions(t *testing.T) {
+	test := assert.New(t)
+
+	versions := []string{"1.10-1", "1:0.1-1", "1.9-1", "1.9-2", "1.0rc1-1", "1.0-1"}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return Compare(versions[i], versions[j]) < 0
+	})
+
+	test.Equal(
+		[]string{"1.0rc1-1", "1.0-1", "1.9-1", "1.9-2", "1.10-1", "1:0.1-1"},
+		versions,
+	)
+}
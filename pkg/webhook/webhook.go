@@ -0,0 +1,248 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/lorg"
+	"github.com/reconquest/karma-go"
+)
+
+const (
+	// HeaderSignature contains HMAC-SHA256 of the body signed by the secret
+	// of the hook in form of sha256=<hex>.
+	HeaderSignature = "X-Aurora-Webhook-Signature"
+	HeaderEvent     = "X-Aurora-Event"
+	HeaderDelivery  = "X-Aurora-Delivery"
+
+	defaultRetries = 5
+	defaultBackoff = 10 * time.Second
+	defaultTimeout = 10 * time.Second
+)
+
+// Hook describes where and which events should be delivered, empty lists
+// match everything.
+type Hook struct {
+	URL      string   `yaml:"url" required:"true"`
+	Secret   string   `yaml:"secret"`
+	Events   []string `yaml:"events"`
+	Packages []string `yaml:"packages"`
+	Statuses []string `yaml:"statuses"`
+}
+
+func (hook Hook) match(event proto.Event) bool {
+	if len(hook.Events) > 0 && !contains(hook.Events, event.Type) {
+		return false
+	}
+
+	if len(hook.Statuses) > 0 && !contains(hook.Statuses, event.Status) {
+		return false
+	}
+
+	if len(hook.Packages) > 0 {
+		for _, glob := range hook.Packages {
+			if matched, _ := path.Match(glob, event.Package); matched {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// Delivery is a record of a single attempt to deliver an event.
+type Delivery struct {
+	ID         string        `bson:"delivery"`
+	URL        string        `bson:"url"`
+	Event      proto.Event   `bson:"event"`
+	Attempt    int           `bson:"attempt"`
+	StatusCode int           `bson:"status_code,omitempty"`
+	Error      string        `bson:"error,omitempty"`
+	Time       time.Time     `bson:"time"`
+	Duration   time.Duration `bson:"duration"`
+}
+
+// Dispatcher delivers events to hooks in background, retrying failed
+// deliveries with exponential backoff and recording every attempt into
+// the delivery log.
+type Dispatcher struct {
+	hooks      []Hook
+	deliveries *mgo.Collection
+	retries    int
+	backoff    time.Duration
+	client     *http.Client
+	log        *lorg.Log
+}
+
+func NewDispatcher(
+	hooks []Hook,
+	deliveries *mgo.Collection,
+	retries int,
+	backoff time.Duration,
+	log *lorg.Log,
+) *Dispatcher {
+	if retries <= 0 {
+		retries = defaultRetries
+	}
+
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+
+	return &Dispatcher{
+		hooks:      hooks,
+		deliveries: deliveries,
+		retries:    retries,
+		backoff:    backoff,
+		client:     &http.Client{Timeout: defaultTimeout},
+		log:        log,
+	}
+}
+
+func (dispatcher *Dispatcher) Notify(event proto.Event) {
+	for _, hook := range dispatcher.hooks {
+		if !hook.match(event) {
+			continue
+		}
+
+		go dispatcher.deliver(hook, event)
+	}
+}
+
+func (dispatcher *Dispatcher) deliver(hook Hook, event proto.Event) {
+	id := bson.NewObjectId().Hex()
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		dispatcher.log.Error(
+			karma.Format(err, "unable to marshal event for webhook"),
+		)
+		return
+	}
+
+	backoff := dispatcher.backoff
+	for attempt := 1; attempt <= dispatcher.retries; attempt++ {
+		delivery := Delivery{
+			ID:      id,
+			URL:     hook.URL,
+			Event:   event,
+			Attempt: attempt,
+			Time:    time.Now(),
+		}
+
+		delivery.StatusCode, err = dispatcher.send(hook, id, event, body)
+		delivery.Duration = time.Since(delivery.Time)
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+
+		dispatcher.record(delivery)
+
+		if err == nil {
+			dispatcher.log.Debugf(
+				"webhook %s: delivered %s of %s",
+				hook.URL, event.Type, event.Package,
+			)
+			return
+		}
+
+		dispatcher.log.Warning(
+			karma.
+				Describe("attempt", attempt).
+				Describe("delivery", id).
+				Format(err, "webhook %s: unable to deliver", hook.URL),
+		)
+
+		if attempt < dispatcher.retries {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	dispatcher.log.Errorf(
+		"webhook %s: giving up delivery %s after %d attempts",
+		hook.URL, id, dispatcher.retries,
+	)
+}
+
+func (dispatcher *Dispatcher) send(
+	hook Hook,
+	id string,
+	event proto.Event,
+	body []byte,
+) (int, error) {
+	request, err := http.NewRequest("POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderEvent, event.Type)
+	request.Header.Set(HeaderDelivery, id)
+
+	if hook.Secret != "" {
+		request.Header.Set(HeaderSignature, Sign(hook.Secret, body))
+	}
+
+	response, err := dispatcher.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+
+	defer response.Body.Close()
+
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf(
+			"unexpected status code: %d", response.StatusCode,
+		)
+	}
+
+	return response.StatusCode, nil
+}
+
+func (dispatcher *Dispatcher) record(delivery Delivery) {
+	if dispatcher.deliveries == nil {
+		return
+	}
+
+	err := dispatcher.deliveries.Insert(delivery)
+	if err != nil {
+		dispatcher.log.Error(
+			karma.Format(err, "unable to record webhook delivery"),
+		)
+	}
+}
+
+// Sign returns value of the signature header for the body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kovetskiy/aurora/pkg/proto"
+	"github.com/kovetskiy/lorg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHook_Match(t *testing.T) {
+	test := assert.New(t)
+
+	event := proto.Event{
+		Type:    proto.EventStatus,
+		Package: "foo-git",
+		Status:  "failure",
+	}
+
+	testcases := []struct {
+		Hook  Hook
+		Match bool
+	}{
+		{Hook{}, true},
+		{Hook{Events: []string{proto.EventStatus}}, true},
+		{Hook{Events: []string{proto.EventPackageAdded}}, false},
+		{Hook{Packages: []string{"*-git"}}, true},
+		{Hook{Packages: []string{"bar", "foo-*"}}, true},
+		{Hook{Packages: []string{"bar"}}, false},
+		{Hook{Statuses: []string{"failure"}}, true},
+		{Hook{Statuses: []string{"success"}}, false},
+	}
+
+	for _, testcase := range testcases {
+		test.Equal(testcase.Match, testcase.Hook.match(event), "%#v", testcase.Hook)
+	}
+}
+
+func TestDispatcher_RetriesAndSignsDelivery(t *testing.T) {
+	test := assert.New(t)
+
+	requests := make(chan *http.Request, 2)
+	bodies := make(chan []byte, 2)
+
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			attempts++
+			if attempts == 1 {
+				writer.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			body, _ := ioutil.ReadAll(request.Body)
+
+			requests <- request
+			bodies <- body
+		},
+	))
+	defer server.Close()
+
+	dispatcher := NewDispatcher(
+		[]Hook{{URL: server.URL, Secret: "secret"}},
+		nil, 2, time.Millisecond, lorg.NewLog(),
+	)
+
+	dispatcher.Notify(proto.Event{Type: proto.EventStatus, Package: "foo"})
+
+	select {
+	case request := <-requests:
+		body := <-bodies
+
+		test.Equal(2, attempts)
+		test.Equal(proto.EventStatus, request.Header.Get(HeaderEvent))
+		test.Equal(Sign("secret", body), request.Header.Get(HeaderSignature))
+	case <-time.After(time.Second):
+		test.Fail("event was not delivered")
+	}
+}